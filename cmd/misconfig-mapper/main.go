@@ -1,14 +1,41 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/intigriti/misconfig-mapper/internal/config"
 	"github.com/intigriti/misconfig-mapper/internal/service"
 )
 
 func main() {
+	// Cancel the root context on SIGINT (Ctrl-C) or SIGTERM to stop gracefully
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Run template subcommands such as "misconfig-mapper templates validate"
+	if len(os.Args) > 1 && os.Args[1] == "templates" {
+		cmd, err := config.ParseTemplatesCommand(os.Args[2:])
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(0)
+		} else if err != nil {
+			fmt.Fprintf(os.Stderr, "[-] Error: %v\n", err)
+			os.Exit(2)
+		}
+
+		if err := service.RunTemplatesCommand(ctx, cmd); err != nil {
+			stop()
+			fmt.Fprintf(os.Stderr, "[-] Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// Parse command line flags
 	cfg, err := config.ParseConfig()
 	if err != nil {
@@ -20,7 +47,14 @@ func main() {
 	svc := service.NewMisconfigMapper(cfg)
 
 	// Run the service
-	if err := svc.Run(); err != nil {
+	if err := svc.Run(ctx); err != nil {
+		stop()
+
+		// The scan summary was already printed, exit with the conventional interrupt code
+		if errors.Is(err, context.Canceled) {
+			os.Exit(130)
+		}
+
 		fmt.Fprintf(os.Stderr, "[-] Error: %v\n", err)
 		os.Exit(1)
 	}
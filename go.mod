@@ -4,7 +4,9 @@ go 1.25.0
 
 require (
 	golang.org/x/term v0.43.0
+	golang.org/x/text v0.40.0
 	golang.org/x/time v0.14.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require golang.org/x/sys v0.44.0 // indirect
@@ -0,0 +1,276 @@
+package checkpoint
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/intigriti/misconfig-mapper/internal/types"
+)
+
+// Entry types stored in a checkpoint file
+const (
+	EntryScan    = "scan"    // Describes the scan the checkpoint belongs to
+	EntryDone    = "done"    // A finished (service ID, target, path) check
+	EntryFinding = "finding" // A result that was reported before the interruption
+)
+
+// flushInterval is how often finished checks are written to disk
+const flushInterval = 5 * time.Second
+
+// Entry represents a single line of a checkpoint file
+type Entry struct {
+	Type      string        `json:"type"`
+	Scan      string        `json:"scan,omitempty"`
+	ServiceID int64         `json:"serviceId"`
+	Target    string        `json:"target,omitempty"`
+	Path      string        `json:"path,omitempty"`
+	Result    *types.Result `json:"result,omitempty"`
+}
+
+// check identifies a single request of a scan
+type check struct {
+	serviceID int64
+	target    string
+	path      string
+}
+
+// hit identifies a service that already yielded a result for a target
+type hit struct {
+	serviceID int64
+	target    string
+}
+
+// Checkpoint records finished checks on disk so that an interrupted scan can be resumed
+type Checkpoint struct {
+	Path string
+
+	mu       sync.Mutex
+	file     *os.File
+	writer   *bufio.Writer
+	done     map[check]bool
+	hits     map[hit]bool
+	findings []types.Result
+	stop     chan struct{}
+	stopped  chan struct{}
+}
+
+// Open loads the checkpoint file at path (if it exists) and opens it for appending new entries.
+// The scan description is used to refuse resuming a checkpoint that was created for a different scan.
+func Open(path, scan string) (*Checkpoint, error) {
+	c := &Checkpoint{
+		Path:    path,
+		done:    make(map[check]bool),
+		hits:    make(map[hit]bool),
+		stop:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+
+	resumed, size, err := c.load(scan)
+	if err != nil {
+		return nil, err
+	}
+
+	c.file, err = os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open checkpoint file: %w", err)
+	}
+	c.writer = bufio.NewWriter(c.file)
+
+	// Drop a last line that was cut off, so that new entries start on a line of their own
+	if err := c.file.Truncate(size); err != nil {
+		c.file.Close()
+		return nil, fmt.Errorf("failed to truncate checkpoint file: %w", err)
+	}
+
+	// Describe the scan when starting a new checkpoint
+	if !resumed {
+		if err := c.write(Entry{Type: EntryScan, Scan: scan}); err != nil {
+			c.file.Close()
+			return nil, err
+		}
+	}
+
+	go c.flushPeriodically()
+
+	return c, nil
+}
+
+// load reads all entries of an existing checkpoint file and reports whether anything was resumed.
+// It also returns the size of the file up to the end of its last complete line.
+func (c *Checkpoint) load(scan string) (bool, int64, error) {
+	file, err := os.Open(c.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, 0, nil
+	}
+	if err != nil {
+		return false, 0, fmt.Errorf("failed to open checkpoint file: %w", err)
+	}
+	defer file.Close()
+
+	resumed := false
+	var size int64
+	reader := bufio.NewReader(file)
+
+	for line := 1; ; line++ {
+		data, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			// The last line was cut off when the previous run was killed
+			if len(data) > 0 {
+				fmt.Fprintf(os.Stderr, "[-] Warning: Skipping incomplete checkpoint entry on line %d\n", line)
+			}
+			break
+		}
+		if err != nil {
+			return false, 0, fmt.Errorf("failed to read checkpoint file: %w", err)
+		}
+		size += int64(len(data))
+
+		var entry Entry
+		if err := json.Unmarshal(data, &entry); err != nil {
+			fmt.Fprintf(os.Stderr, "[-] Warning: Skipping malformed checkpoint entry on line %d\n", line)
+			continue
+		}
+
+		switch entry.Type {
+		case EntryScan:
+			if entry.Scan != scan {
+				return false, 0, fmt.Errorf("checkpoint file %q belongs to a different scan (%s)", c.Path, entry.Scan)
+			}
+		case EntryDone:
+			c.done[check{entry.ServiceID, entry.Target, entry.Path}] = true
+		case EntryFinding:
+			c.hits[hit{entry.ServiceID, entry.Target}] = true
+			if entry.Result != nil {
+				c.findings = append(c.findings, *entry.Result)
+			}
+		}
+		resumed = true
+	}
+
+	return resumed, size, nil
+}
+
+// Findings returns the results that were recorded before the scan was interrupted
+func (c *Checkpoint) Findings() []types.Result {
+	return c.findings
+}
+
+// Resumed returns the number of checks that were already finished
+func (c *Checkpoint) Resumed() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.done)
+}
+
+// IsDone reports whether a path of a service was already checked against a target
+func (c *Checkpoint) IsDone(serviceID int64, target, path string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.done[check{serviceID, target, path}]
+}
+
+// HasFinding reports whether a service already yielded a result for a target
+func (c *Checkpoint) HasFinding(serviceID int64, target string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.hits[hit{serviceID, target}]
+}
+
+// MarkDone records a finished check, it is written to disk on the next periodic flush
+func (c *Checkpoint) MarkDone(serviceID int64, target, path string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.done[check{serviceID, target, path}] = true
+	return c.writeLocked(Entry{Type: EntryDone, ServiceID: serviceID, Target: target, Path: path})
+}
+
+// AddFinding records a result and immediately writes it to disk
+func (c *Checkpoint) AddFinding(serviceID int64, target string, result types.Result) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.hits[hit{serviceID, target}] = true
+	if err := c.writeLocked(Entry{Type: EntryFinding, ServiceID: serviceID, Target: target, Result: &result}); err != nil {
+		return err
+	}
+
+	return c.flushLocked()
+}
+
+// Close flushes all pending entries and closes the checkpoint file
+func (c *Checkpoint) Close() error {
+	close(c.stop)
+	<-c.stopped
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.flushLocked(); err != nil {
+		c.file.Close()
+		return err
+	}
+
+	return c.file.Close()
+}
+
+// write appends an entry to the checkpoint file
+func (c *Checkpoint) write(entry Entry) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.writeLocked(entry)
+}
+
+// writeLocked appends an entry to the checkpoint file, the caller must hold the lock
+func (c *Checkpoint) writeLocked(entry Entry) error {
+	d, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal checkpoint entry: %w", err)
+	}
+
+	if _, err := c.writer.Write(append(d, '\n')); err != nil {
+		return fmt.Errorf("failed to write checkpoint entry: %w", err)
+	}
+
+	return nil
+}
+
+// flushLocked writes all buffered entries to disk, the caller must hold the lock
+func (c *Checkpoint) flushLocked() error {
+	if err := c.writer.Flush(); err != nil {
+		return fmt.Errorf("failed to flush checkpoint file: %w", err)
+	}
+
+	return c.file.Sync()
+}
+
+// flushPeriodically regularly writes buffered entries to disk until the checkpoint is closed
+func (c *Checkpoint) flushPeriodically() {
+	defer close(c.stopped)
+
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			c.mu.Lock()
+			if err := c.flushLocked(); err != nil {
+				fmt.Fprintf(os.Stderr, "[-] Error: %v\n", err)
+			}
+			c.mu.Unlock()
+		case <-c.stop:
+			return
+		}
+	}
+}
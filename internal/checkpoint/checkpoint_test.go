@@ -0,0 +1,84 @@
+package checkpoint
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResumeAfterCutOffLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scan.checkpoint")
+
+	c, err := Open(path, "target=acme")
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	if err := c.MarkDone(1, "acme", "/"); err != nil {
+		t.Fatalf("MarkDone() error = %v", err)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	// Simulate a run that was killed while writing an entry
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := file.WriteString(`{"type":"done","serviceId":2,"tar`); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+
+	c, err = Open(path, "target=acme")
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	if !c.IsDone(1, "acme", "/") {
+		t.Errorf("IsDone(1) = false, want true")
+	}
+	if err := c.MarkDone(3, "acme", "/"); err != nil {
+		t.Fatalf("MarkDone() error = %v", err)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), `"serviceId":2`) {
+		t.Errorf("checkpoint file still contains the cut-off entry:\n%s", data)
+	}
+
+	// The entry written after resuming must be readable by the next run
+	c, err = Open(path, "target=acme")
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer c.Close()
+
+	for _, id := range []int64{1, 3} {
+		if !c.IsDone(id, "acme", "/") {
+			t.Errorf("IsDone(%d) = false, want true", id)
+		}
+	}
+}
+
+func TestOpenDifferentScan(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scan.checkpoint")
+
+	c, err := Open(path, "target=acme")
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	if _, err := Open(path, "target=example"); err == nil {
+		t.Errorf("Open() with a different scan succeeded, want an error")
+	}
+}
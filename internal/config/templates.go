@@ -0,0 +1,85 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/intigriti/misconfig-mapper/internal/types"
+	"github.com/intigriti/misconfig-mapper/pkg/templates"
+)
+
+// Template subcommands
+const (
+	TemplatesValidate = "validate"
+	TemplatesTest     = "test"
+	TemplatesRollback = "rollback"
+	TemplatesManifest = "manifest"
+	TemplatesExport   = "export"
+)
+
+// templatesActions are all supported template subcommands
+var templatesActions = []string{TemplatesValidate, TemplatesTest, TemplatesRollback, TemplatesManifest, TemplatesExport}
+
+// TemplatesCommand represents the configuration of a "templates" subcommand
+type TemplatesCommand struct {
+	Action         string
+	TemplatesPaths []string
+	Paths          []string // Template files or folders to act on, the templates folders if none are given
+	ServiceID      string   // Services to act on, by ID or name
+	Version        int64    // Version of a generated manifest, 0 to increment the version of the existing manifest
+	SigningKey     string   // Private key file to sign a generated manifest with
+	Force          bool     // Overwrite existing files when exporting the embedded templates
+	Verbosity      types.VerbosityLevel
+}
+
+// ParseTemplatesCommand parses the arguments of a "templates" subcommand, such as "templates validate ./templates"
+func ParseTemplatesCommand(args []string) (*TemplatesCommand, error) {
+	if len(args) == 0 || !slices.Contains(templatesActions, args[0]) {
+		return nil, fmt.Errorf("usage: misconfig-mapper templates <%s> [flags] [paths...]", strings.Join(templatesActions, "|"))
+	}
+
+	fs := flag.NewFlagSet("templates "+args[0], flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+
+	var templatesFlags stringList
+	fs.Var(&templatesFlags, "templates", "Specify a templates folder location (default \"./templates\"). Can be specified multiple times.")
+	serviceFlag := fs.String("service", "*", "Specify the service ID(s) or name(s) to test, separated by commas. Use \"*\" to test all services.")
+	versionFlag := fs.Int64("version", 0, "Specify the version of the generated manifest (default: the version of the existing manifest + 1)")
+	keyFlag := fs.String("key", "", "Specify a PEM encoded Ed25519 private key file to sign the generated manifest with")
+	forceFlag := fs.Bool("force", false, "Overwrite existing files when exporting the embedded templates")
+	verbosityFlag := fs.Int("verbose", 2, "Set output verbosity level. Levels: 0 (=silent, only display problems), 1 (=default), 2 (=verbose, log all messages)")
+
+	if err := fs.Parse(args[1:]); err != nil {
+		return nil, err
+	}
+
+	// Validate verbosity level
+	if *verbosityFlag < 0 || *verbosityFlag > 2 {
+		fmt.Fprintf(os.Stderr, "[-] Error: invalid verbosity level: %d (must be 0, 1, or 2)... Falling back to default verbosity level!\n", *verbosityFlag)
+		*verbosityFlag = 2
+	}
+
+	cmd := &TemplatesCommand{
+		Action:         args[0],
+		TemplatesPaths: templatesFlags,
+		Paths:          fs.Args(),
+		ServiceID:      *serviceFlag,
+		Version:        *versionFlag,
+		SigningKey:     *keyFlag,
+		Force:          *forceFlag,
+		Verbosity:      types.VerbosityLevel(*verbosityFlag),
+	}
+
+	// Fall back to the default templates folder
+	if len(cmd.TemplatesPaths) == 0 {
+		cmd.TemplatesPaths = []string{templates.DefaultDir}
+	}
+	if len(cmd.Paths) == 0 {
+		cmd.Paths = cmd.TemplatesPaths
+	}
+
+	return cmd, nil
+}
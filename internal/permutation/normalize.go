@@ -0,0 +1,150 @@
+package permutation
+
+import (
+	"net/url"
+	"slices"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Legal entity suffixes that are never part of a tenant name
+var legalSuffixes = []string{
+	"inc", "incorporated", "llc", "llp", "lp", "ltd", "limited", "plc",
+	"gmbh", "mbh", "ag", "kg", "ug", "se", "sa", "sas", "sarl", "srl",
+	"spa", "bv", "nv", "oy", "ab", "as", "aps", "pty", "pte", "kk",
+}
+
+// Company designators that are often (but not always) left out of a tenant name
+var designators = []string{
+	"corp", "corporation", "co", "company", "group", "holding", "holdings",
+}
+
+// Multi-label public suffixes, a domain is reduced to the label right before them
+var publicSuffixes = []string{
+	"co.uk", "org.uk", "ac.uk", "gov.uk", "ltd.uk", "plc.uk", "me.uk",
+	"com.au", "net.au", "org.au", "co.nz", "org.nz", "co.jp", "ne.jp",
+	"co.kr", "co.in", "co.za", "co.il", "com.br", "com.mx", "com.ar",
+	"com.cn", "com.hk", "com.tw", "com.sg", "com.my", "com.tr", "com.pl",
+}
+
+// Characters that do not decompose into a base letter and a diacritic
+var transliterations = strings.NewReplacer(
+	"ß", "ss", "æ", "ae", "œ", "oe", "ø", "o", "ł", "l", "đ", "d", "ð", "d", "þ", "th", "ı", "i",
+)
+
+// Normalizer turns organization names (or domains) into keywords that are valid in tenant names
+type Normalizer struct {
+	DeriveFromDomain bool // Reduce domains such as acme.co.uk to their organization keyword (acme)
+}
+
+// Keywords returns the normalized keywords for a target, the most likely keyword first
+func (n *Normalizer) Keywords(target string) []string {
+	target = Transliterate(strings.ToLower(strings.TrimSpace(target)))
+
+	if looksLikeDomain(target) {
+		if !n.DeriveFromDomain {
+			return []string{target}
+		}
+		target = DomainKeyword(target)
+	}
+
+	// Collapse whitespace and punctuation, keeping abbreviated legal suffixes such as "S.A." or "A/S" intact
+	var words []string
+	for _, chunk := range strings.FieldsFunc(target, func(r rune) bool { return unicode.IsSpace(r) || r == ',' }) {
+		if abbreviation := strings.NewReplacer(".", "", "/", "").Replace(chunk); slices.Contains(legalSuffixes, abbreviation) {
+			words = append(words, abbreviation)
+			continue
+		}
+
+		words = append(words, strings.FieldsFunc(chunk, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})...)
+	}
+
+	// Strip trailing legal suffixes, but never the whole name
+	for len(words) > 1 && slices.Contains(legalSuffixes, words[len(words)-1]) {
+		words = words[:len(words)-1]
+	}
+	if len(words) == 0 {
+		return []string{target}
+	}
+
+	var keywords []string
+	add := func(words []string) {
+		for _, separator := range []string{"", "-"} {
+			keyword := strings.Join(words, separator)
+			if keyword != "" && !slices.Contains(keywords, keyword) {
+				keywords = append(keywords, keyword)
+			}
+		}
+	}
+
+	add(words)
+
+	// Also try the name without company designators (Acme Corp -> acme)
+	stripped := words
+	for len(stripped) > 1 && slices.Contains(designators, stripped[len(stripped)-1]) {
+		stripped = stripped[:len(stripped)-1]
+	}
+	if len(stripped) != len(words) {
+		add(stripped)
+	}
+
+	return keywords
+}
+
+// Transliterate replaces accented characters with their closest ASCII equivalent
+func Transliterate(s string) string {
+	t := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+
+	result, _, err := transform.String(t, transliterations.Replace(s))
+	if err != nil {
+		return s
+	}
+
+	return result
+}
+
+// DomainKeyword derives the organization keyword from a domain, e.g. https://www.acme.co.uk -> acme
+func DomainKeyword(domain string) string {
+	host := domain
+	if strings.Contains(domain, "://") {
+		if u, err := url.Parse(domain); err == nil && u.Hostname() != "" {
+			host = u.Hostname()
+		}
+	}
+	host, _, _ = strings.Cut(host, "/")
+	host, _, _ = strings.Cut(host, ":")
+
+	labels := strings.Split(strings.Trim(host, "."), ".")
+	if len(labels) < 2 {
+		return host
+	}
+
+	// Drop the public suffix
+	suffixLabels := 1
+	for _, suffix := range publicSuffixes {
+		if strings.HasSuffix(host, "."+suffix) {
+			suffixLabels = strings.Count(suffix, ".") + 1
+			break
+		}
+	}
+	if len(labels) <= suffixLabels {
+		return host
+	}
+
+	return labels[len(labels)-suffixLabels-1]
+}
+
+// looksLikeDomain reports whether a target is a domain (or URL) rather than an organization name
+func looksLikeDomain(target string) bool {
+	if strings.Contains(target, "://") {
+		return true
+	}
+
+	return strings.Contains(target, ".") && !strings.ContainsAny(target, " ,")
+}
@@ -0,0 +1,96 @@
+package permutation
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestKeywords(t *testing.T) {
+	tests := []struct {
+		target           string
+		deriveFromDomain bool
+		want             []string
+	}{
+		{"Acme", false, []string{"acme"}},
+		{"Acme Inc.", false, []string{"acme"}},
+		{"Acme Corp, Inc.", false, []string{"acmecorp", "acme-corp", "acme"}},
+		{"Acme Holding GmbH", false, []string{"acmeholding", "acme-holding", "acme"}},
+		{"Société Générale S.A.", false, []string{"societegenerale", "societe-generale"}},
+		{"Ørsted A/S", false, []string{"orsted"}},
+		{"Ltd", false, []string{"ltd"}},
+		{"acme.co.uk", false, []string{"acme.co.uk"}},
+		{"https://www.acme.co.uk/login", true, []string{"acme"}},
+		{"shop.acme.com", true, []string{"acme"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			n := &Normalizer{DeriveFromDomain: tt.deriveFromDomain}
+			if got := n.Keywords(tt.target); !slices.Equal(got, tt.want) {
+				t.Errorf("Keywords(%q) = %v, want %v", tt.target, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDomainKeyword(t *testing.T) {
+	tests := []struct {
+		domain string
+		want   string
+	}{
+		{"acme.com", "acme"},
+		{"www.acme.co.uk", "acme"},
+		{"https://login.acme.com.au:8443/path", "acme"},
+		{"acme", "acme"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.domain, func(t *testing.T) {
+			if got := DomainKeyword(tt.domain); got != tt.want {
+				t.Errorf("DomainKeyword(%q) = %q, want %q", tt.domain, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerateNormalized(t *testing.T) {
+	tests := []struct {
+		name       string
+		target     string
+		normalizer *Normalizer
+		want       []string
+	}{
+		{
+			name:       "keywords",
+			target:     "Acme Corp",
+			normalizer: &Normalizer{},
+			want: []string{
+				"Acme Corp", "acmecorp", "acme-corp", "acme",
+				"acmecorp-dev", "acmecorp-prod", "acmecorpdev", "acmecorpprod",
+				"acme-corp-dev", "acme-corp-prod", "acme-corpdev", "acme-corpprod",
+				"acme-dev", "acme-prod", "acmedev", "acmeprod",
+			},
+		},
+		{
+			name:       "derived from domain",
+			target:     "acme.co.uk",
+			normalizer: &Normalizer{DeriveFromDomain: true},
+			want:       []string{"acme.co.uk", "acme", "acme-dev", "acme-prod", "acmedev", "acmeprod"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := NewEngine(testProfile())
+			if err != nil {
+				t.Fatalf("NewEngine() error = %v", err)
+			}
+			e.SetNormalizer(tt.normalizer)
+
+			// The original target is always generated first
+			if got := e.Generate(tt.target); !slices.Equal(got, tt.want) {
+				t.Errorf("Generate(%q) = %v, want %v", tt.target, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,284 @@
+package permutation
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Reserved pattern placeholders, all other placeholders refer to a wordlist of the profile
+const (
+	TargetPlaceholder    = "target" // The (cleaned) target keyword
+	SeparatorPlaceholder = "sep"    // One of the separators of the profile
+)
+
+// DefaultProfile is the name of the profile used when none is selected
+const DefaultProfile = "default"
+
+// placeholderRegex matches pattern placeholders such as {target}, {sep} or {suffix}
+var placeholderRegex = regexp.MustCompile(`\{([a-zA-Z0-9_-]+)\}`)
+
+// Common domain suffixes for permutation generation
+var suffixes = []string{
+	"com", "net", "org", "io", "fr", "ltd", "app", "prod", "internal",
+	"dev", "development", "devops", "logs", "logging", "admin", "log",
+	"stage", "staging", "stg", "production", "dev-only", "cicd",
+	"employee-only", "testing", "secret", "kibana", "employees",
+	"partners", "sso", "saml", "tickets", "issues", "oauth2",
+}
+
+// Common prefixes for permutation generation
+var prefixes = []string{
+	"dev", "development", "stage", "staging", "stg", "test", "testing",
+	"prod", "production", "internal", "corp", "admin", "my", "team", "app",
+}
+
+// Environment names for permutation generation
+var environments = []string{
+	"dev", "development", "test", "testing", "qa", "uat", "stage",
+	"staging", "stg", "preprod", "prod", "production", "sandbox", "demo",
+}
+
+// builtinProfiles contains the profiles that ship with misconfig-mapper
+var builtinProfiles = map[string]Profile{
+	DefaultProfile: {
+		Name:       DefaultProfile,
+		Separators: []string{".", "-", ""},
+		Wordlists:  map[string][]string{"suffix": suffixes},
+		Patterns:   []string{"{target}{sep}{suffix}"},
+	},
+	"minimal": {
+		Name:       "minimal",
+		Separators: []string{"-", ""},
+		Wordlists:  map[string][]string{"env": environments},
+		Patterns:   []string{"{target}{sep}{env}"},
+	},
+	"extended": {
+		Name:       "extended",
+		Separators: []string{".", "-", ""},
+		Wordlists:  map[string][]string{"suffix": suffixes, "prefix": prefixes, "env": environments},
+		Patterns: []string{
+			"{target}{sep}{suffix}",
+			"{prefix}{sep}{target}",
+			"{target}{sep}{env}",
+			"{prefix}{sep}{target}{sep}{env}",
+		},
+	},
+}
+
+// Profile describes how permutations are generated for a target
+type Profile struct {
+	Name       string              `json:"name"`
+	Separators []string            `json:"separators"`
+	Wordlists  map[string][]string `json:"wordlists"`
+	Patterns   []string            `json:"patterns"`
+}
+
+// Profiles returns the names of all built-in profiles
+func Profiles() []string {
+	return slices.Sorted(maps.Keys(builtinProfiles))
+}
+
+// LoadProfile returns the built-in profile with the given name, or loads a JSON profile from a file
+func LoadProfile(nameOrPath string) (Profile, error) {
+	if profile, ok := builtinProfiles[strings.ToLower(nameOrPath)]; ok {
+		return profile.clone(), nil
+	}
+
+	data, err := os.ReadFile(nameOrPath)
+	if err != nil {
+		return Profile{}, fmt.Errorf("unknown permutation profile %q (built-in profiles: %s)",
+			nameOrPath, strings.Join(Profiles(), ", "))
+	}
+
+	var profile Profile
+	if err := json.Unmarshal(data, &profile); err != nil {
+		return Profile{}, fmt.Errorf("failed decoding permutation profile '%s': %w", nameOrPath, err)
+	}
+	if profile.Name == "" {
+		profile.Name = nameOrPath
+	}
+	if profile.Wordlists == nil {
+		profile.Wordlists = make(map[string][]string)
+	}
+
+	return profile, nil
+}
+
+// clone returns a copy of the profile that can be modified safely
+func (p Profile) clone() Profile {
+	wordlists := make(map[string][]string, len(p.Wordlists))
+	for name, words := range p.Wordlists {
+		wordlists[name] = slices.Clone(words)
+	}
+
+	return Profile{
+		Name:       p.Name,
+		Separators: slices.Clone(p.Separators),
+		Wordlists:  wordlists,
+		Patterns:   slices.Clone(p.Patterns),
+	}
+}
+
+// LoadWordlist reads a wordlist file (one word per line, lines starting with # are ignored) into the profile
+func (p *Profile) LoadWordlist(name, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open wordlist: %w", err)
+	}
+	defer file.Close()
+
+	words := []string{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		word := strings.ToLower(strings.TrimSpace(scanner.Text()))
+		if word == "" || strings.HasPrefix(word, "#") {
+			continue
+		}
+		words = append(words, word)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read wordlist: %w", err)
+	}
+
+	p.Wordlists[name] = words
+	return nil
+}
+
+// Engine generates permutations of a target based on a profile
+type Engine struct {
+	Profile    Profile
+	Normalizer *Normalizer // Optional, turns organization names into keywords before permutation
+	patterns   []pattern
+}
+
+// pattern is a parsed permutation pattern
+type pattern struct {
+	raw          string
+	placeholders []string // Distinct placeholders, excluding {target}
+}
+
+// NewEngine creates a new permutation engine and validates the patterns of the profile
+func NewEngine(profile Profile) (*Engine, error) {
+	e := &Engine{Profile: profile}
+
+	for _, raw := range profile.Patterns {
+		p := pattern{raw: raw}
+		hasTarget := false
+
+		for _, match := range placeholderRegex.FindAllStringSubmatch(raw, -1) {
+			name := match[1]
+			switch {
+			case name == TargetPlaceholder:
+				hasTarget = true
+				continue
+			case name == SeparatorPlaceholder:
+			case profile.Wordlists[name] == nil:
+				return nil, fmt.Errorf("permutation pattern %q references unknown wordlist %q", raw, name)
+			}
+
+			if !slices.Contains(p.placeholders, name) {
+				p.placeholders = append(p.placeholders, name)
+			}
+		}
+
+		if !hasTarget {
+			return nil, fmt.Errorf("permutation pattern %q is missing the {%s} placeholder", raw, TargetPlaceholder)
+		}
+
+		e.patterns = append(e.patterns, p)
+	}
+
+	return e, nil
+}
+
+// DefaultEngine returns an engine for the default profile
+func DefaultEngine() *Engine {
+	e, _ := NewEngine(builtinProfiles[DefaultProfile].clone())
+	return e
+}
+
+// Generate returns the original target followed by all unique permutations of it.
+// With a normalizer, every keyword derived from the target is permuted instead of the original target,
+// which is still returned as is.
+func (e *Engine) Generate(target string) []string {
+	var permutations []string
+	seen := make(map[string]bool)
+
+	add := func(permutation string) {
+		if !seen[permutation] {
+			seen[permutation] = true
+			permutations = append(permutations, permutation)
+		}
+	}
+
+	// Always add original target
+	add(target)
+
+	// Clean target
+	keywords := []string{strings.TrimSpace(strings.ToLower(target))}
+	if e.Normalizer != nil {
+		keywords = e.Normalizer.Keywords(target)
+	}
+
+	for _, keyword := range keywords {
+		add(keyword)
+	}
+
+	for _, keyword := range keywords {
+		for _, p := range e.patterns {
+			e.expand(p, 0, map[string]string{TargetPlaceholder: keyword}, add)
+		}
+	}
+
+	return permutations
+}
+
+// SetNormalizer sets the normalizer used to derive keywords from each target
+func (e *Engine) SetNormalizer(n *Normalizer) {
+	e.Normalizer = n
+}
+
+// expand recursively binds every placeholder of a pattern to each of its values.
+// A placeholder that occurs several times in a pattern is bound to the same value everywhere.
+func (e *Engine) expand(p pattern, i int, values map[string]string, emit func(string)) {
+	if i == len(p.placeholders) {
+		emit(placeholderRegex.ReplaceAllStringFunc(p.raw, func(placeholder string) string {
+			return values[placeholder[1:len(placeholder)-1]]
+		}))
+		return
+	}
+
+	name := p.placeholders[i]
+	words := e.Profile.Wordlists[name]
+	if name == SeparatorPlaceholder {
+		words = e.Profile.Separators
+	}
+
+	for _, word := range words {
+		values[name] = word
+		e.expand(p, i+1, values, emit)
+	}
+	delete(values, name)
+}
+
+// ParseWordlistFlag parses a "name=path" wordlist flag value
+func ParseWordlistFlag(value string) (string, string, error) {
+	name, path, ok := strings.Cut(value, "=")
+	name = strings.TrimSpace(name)
+	if !ok || name == "" || strings.TrimSpace(path) == "" {
+		return "", "", fmt.Errorf("invalid wordlist %q, expected name=path (e.g. prefix=./prefixes.txt)", value)
+	}
+
+	if name == TargetPlaceholder || name == SeparatorPlaceholder {
+		return "", "", fmt.Errorf("invalid wordlist name %q, {%s} and {%s} are reserved", name, TargetPlaceholder, SeparatorPlaceholder)
+	}
+
+	return name, strings.TrimSpace(path), nil
+}
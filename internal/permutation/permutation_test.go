@@ -0,0 +1,143 @@
+package permutation
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// testProfile is a small profile with a single wordlist
+func testProfile() Profile {
+	return Profile{
+		Name:       "test",
+		Separators: []string{"-", ""},
+		Wordlists:  map[string][]string{"env": {"dev", "prod"}},
+		Patterns:   []string{"{target}{sep}{env}"},
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		target   string
+		want     []string
+	}{
+		{
+			name:   "target",
+			target: "acme",
+			want:   []string{"acme", "acme-dev", "acme-prod", "acmedev", "acmeprod"},
+		},
+		{
+			name:   "cleaned target",
+			target: " Acme ",
+			want:   []string{" Acme ", "acme", "acme-dev", "acme-prod", "acmedev", "acmeprod"},
+		},
+		{
+			name:     "repeated placeholder",
+			patterns: []string{"{env}{sep}{target}{sep}{env}"},
+			target:   "acme",
+			want:     []string{"acme", "dev-acme-dev", "devacmedev", "prod-acme-prod", "prodacmeprod"},
+		},
+		{
+			name:     "duplicate permutations",
+			patterns: []string{"{target}{sep}{env}", "{target}{env}"},
+			target:   "acme",
+			want:     []string{"acme", "acme-dev", "acme-prod", "acmedev", "acmeprod"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			profile := testProfile()
+			if tt.patterns != nil {
+				profile.Patterns = tt.patterns
+			}
+
+			e, err := NewEngine(profile)
+			if err != nil {
+				t.Fatalf("NewEngine() error = %v", err)
+			}
+
+			if got := e.Generate(tt.target); !slices.Equal(got, tt.want) {
+				t.Errorf("Generate(%q) = %v, want %v", tt.target, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewEngineErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+	}{
+		{"unknown wordlist", []string{"{target}{sep}{suffix}"}},
+		{"missing target", []string{"{env}{sep}acme"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			profile := testProfile()
+			profile.Patterns = tt.patterns
+
+			if _, err := NewEngine(profile); err == nil {
+				t.Errorf("NewEngine(%v) succeeded, want an error", tt.patterns)
+			}
+		})
+	}
+}
+
+func TestLoadWordlist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "envs.txt")
+	if err := os.WriteFile(path, []byte("# Environments\nQA\n\n  uat  \n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	profile, err := LoadProfile("minimal")
+	if err != nil {
+		t.Fatalf("LoadProfile() error = %v", err)
+	}
+	if err := profile.LoadWordlist("env", path); err != nil {
+		t.Fatalf("LoadWordlist() error = %v", err)
+	}
+
+	if got := profile.Wordlists["env"]; !slices.Equal(got, []string{"qa", "uat"}) {
+		t.Errorf("wordlist = %v, want [qa uat]", got)
+	}
+
+	// The built-in profile is not modified
+	builtin, _ := LoadProfile("minimal")
+	if slices.Equal(builtin.Wordlists["env"], profile.Wordlists["env"]) {
+		t.Errorf("LoadWordlist() modified the built-in profile")
+	}
+}
+
+func TestParseWordlistFlag(t *testing.T) {
+	tests := []struct {
+		value    string
+		wantName string
+		wantPath string
+		wantErr  bool
+	}{
+		{"prefix=./prefixes.txt", "prefix", "./prefixes.txt", false},
+		{" env = envs.txt ", "env", "envs.txt", false},
+		{"prefixes.txt", "", "", true},
+		{"=prefixes.txt", "", "", true},
+		{"prefix=", "", "", true},
+		{"target=targets.txt", "", "", true},
+		{"sep=separators.txt", "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			name, path, err := ParseWordlistFlag(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseWordlistFlag() error = %v, want error %v", err, tt.wantErr)
+			}
+			if name != tt.wantName || path != tt.wantPath {
+				t.Errorf("ParseWordlistFlag() = %q, %q, want %q, %q", name, path, tt.wantName, tt.wantPath)
+			}
+		})
+	}
+}
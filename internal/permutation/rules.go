@@ -0,0 +1,50 @@
+package permutation
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/intigriti/misconfig-mapper/internal/types"
+)
+
+// Filter drops candidate names that a service does not allow
+type Filter struct {
+	rules   types.PermutationRules
+	allowed *regexp.Regexp
+}
+
+// NewFilter compiles the permutation rules of a service into a filter
+func NewFilter(rules types.PermutationRules) (*Filter, error) {
+	f := &Filter{rules: rules}
+
+	if rules.AllowedCharacters != "" {
+		re, err := regexp.Compile(fmt.Sprintf("^[%s]*$", rules.AllowedCharacters))
+		if err != nil {
+			return nil, fmt.Errorf("invalid allowed characters %q: %w", rules.AllowedCharacters, err)
+		}
+		f.allowed = re
+	}
+
+	return f, nil
+}
+
+// Allows reports whether the candidate name is valid for the service
+func (f *Filter) Allows(candidate string) bool {
+	length := utf8.RuneCountInString(candidate)
+	if f.rules.MinLength > 0 && length < f.rules.MinLength {
+		return false
+	}
+	if f.rules.MaxLength > 0 && length > f.rules.MaxLength {
+		return false
+	}
+
+	for _, connector := range f.rules.ForbiddenConnectors {
+		if connector != "" && strings.Contains(candidate, connector) {
+			return false
+		}
+	}
+
+	return f.allowed == nil || f.allowed.MatchString(candidate)
+}
@@ -0,0 +1,54 @@
+package permutation
+
+import (
+	"testing"
+
+	"github.com/intigriti/misconfig-mapper/internal/types"
+)
+
+func TestFilter(t *testing.T) {
+	tests := []struct {
+		name      string
+		rules     types.PermutationRules
+		candidate string
+		want      bool
+	}{
+		{"no rules", types.PermutationRules{}, "Acme_Corp.dev", true},
+		{"allowed characters", types.PermutationRules{AllowedCharacters: "a-z0-9-"}, "acme-dev", true},
+		{"disallowed character", types.PermutationRules{AllowedCharacters: "a-z0-9-"}, "acme.dev", false},
+		{"uppercase", types.PermutationRules{AllowedCharacters: "a-z0-9"}, "Acme", false},
+		{"minimum length", types.PermutationRules{MinLength: 3}, "acm", true},
+		{"too short", types.PermutationRules{MinLength: 3}, "ac", false},
+		{"maximum length", types.PermutationRules{MaxLength: 4}, "acme", true},
+		{"too long", types.PermutationRules{MaxLength: 4}, "acmes", false},
+		{"length in characters", types.PermutationRules{MaxLength: 4}, "ácmé", true},
+		{"forbidden connector", types.PermutationRules{ForbiddenConnectors: []string{"--", "."}}, "acme--dev", false},
+		{"other connector", types.PermutationRules{ForbiddenConnectors: []string{"--", "."}}, "acme-dev", true},
+		{"empty connector", types.PermutationRules{ForbiddenConnectors: []string{""}}, "acme", true},
+		{
+			name:      "all rules",
+			rules:     types.PermutationRules{AllowedCharacters: "a-z0-9-", MinLength: 3, MaxLength: 63, ForbiddenConnectors: []string{"--"}},
+			candidate: "acme-staging",
+			want:      true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := NewFilter(tt.rules)
+			if err != nil {
+				t.Fatalf("NewFilter() error = %v", err)
+			}
+
+			if got := f.Allows(tt.candidate); got != tt.want {
+				t.Errorf("Allows(%q) = %v, want %v", tt.candidate, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewFilterInvalidCharacters(t *testing.T) {
+	if _, err := NewFilter(types.PermutationRules{AllowedCharacters: "z-a"}); err == nil {
+		t.Errorf("NewFilter() with an invalid character range succeeded, want an error")
+	}
+}
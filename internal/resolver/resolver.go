@@ -0,0 +1,180 @@
+package resolver
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Verdict is the outcome of resolving a hostname ahead of the HTTP checks
+type Verdict int
+
+const (
+	// Unknown means the lookup failed, the host still has to be checked
+	Unknown Verdict = iota
+	// Exists means the host resolved to records that are specific to it
+	Exists
+	// NotExists means the host does not exist (NXDOMAIN) and can be skipped
+	NotExists
+	// Wildcard means the host only resolved to the wildcard records of its zone, so it may not exist
+	Wildcard
+)
+
+// String returns a readable representation of the verdict
+func (v Verdict) String() string {
+	switch v {
+	case Exists:
+		return "exists"
+	case NotExists:
+		return "not exists"
+	case Wildcard:
+		return "wildcard"
+	default:
+		return "unknown"
+	}
+}
+
+// Resolver resolves hostnames concurrently and caches the results
+type Resolver struct {
+	Servers    []string      // DNS servers to use (host:port), the system resolver is used when empty
+	Timeout    time.Duration // Timeout of a single lookup
+	ProbeLabel string        // Random label used to detect wildcard DNS records
+
+	resolver *net.Resolver
+	next     atomic.Uint64
+	mu       sync.Mutex
+	cache    map[string]*lookup
+}
+
+// lookup holds the (possibly in-flight) result of resolving a hostname
+type lookup struct {
+	done    chan struct{}
+	addrs   []string
+	verdict Verdict
+}
+
+// New creates a new resolver that spreads its lookups across the given DNS servers
+func New(servers []string, timeout time.Duration) *Resolver {
+	label := make([]byte, 8)
+	_, _ = rand.Read(label)
+
+	r := &Resolver{
+		Timeout:    timeout,
+		ProbeLabel: "misconfig-mapper-" + hex.EncodeToString(label),
+		cache:      make(map[string]*lookup),
+	}
+
+	for _, server := range servers {
+		server = strings.TrimSpace(server)
+		if server == "" {
+			continue
+		}
+		if _, _, err := net.SplitHostPort(server); err != nil {
+			server = net.JoinHostPort(server, "53")
+		}
+		r.Servers = append(r.Servers, server)
+	}
+
+	r.resolver = net.DefaultResolver
+	if len(r.Servers) > 0 {
+		r.resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				// Rotate through the configured DNS servers
+				server := r.Servers[r.next.Add(1)%uint64(len(r.Servers))]
+
+				var d net.Dialer
+				return d.DialContext(ctx, network, server)
+			},
+		}
+	}
+
+	return r
+}
+
+// Resolve looks up a hostname, concurrent lookups of the same hostname share a single query
+func (r *Resolver) Resolve(ctx context.Context, host string) ([]string, Verdict) {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+
+	r.mu.Lock()
+	l, ok := r.cache[host]
+	if !ok {
+		l = &lookup{done: make(chan struct{})}
+		r.cache[host] = l
+	}
+	r.mu.Unlock()
+
+	if ok {
+		select {
+		case <-l.done:
+			return l.addrs, l.verdict
+		case <-ctx.Done():
+			return nil, Unknown
+		}
+	}
+
+	l.addrs, l.verdict = r.resolve(ctx, host)
+	close(l.done)
+
+	// Don't cache lookups that were aborted by a cancelled scan
+	if ctx.Err() != nil {
+		r.mu.Lock()
+		delete(r.cache, host)
+		r.mu.Unlock()
+	}
+
+	return l.addrs, l.verdict
+}
+
+// resolve performs the actual lookup of a hostname
+func (r *Resolver) resolve(ctx context.Context, host string) ([]string, Verdict) {
+	// IP addresses don't need to be resolved
+	if net.ParseIP(host) != nil {
+		return []string{host}, Exists
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, r.Timeout)
+	defer cancel()
+
+	addrs, err := r.resolver.LookupHost(ctx, host)
+	if err != nil {
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			return nil, NotExists
+		}
+		return nil, Unknown
+	}
+
+	slices.Sort(addrs)
+	return addrs, Exists
+}
+
+// Check resolves a hostname and compares it with the answer for the wildcard probe hostname of the same zone.
+// Hosts that only resolve to the wildcard records of their zone can't be told apart from non-existent ones,
+// so they are reported as Wildcard instead of Exists to keep wildcard zones from producing false positives.
+func (r *Resolver) Check(ctx context.Context, host, probeHost string) Verdict {
+	addrs, verdict := r.Resolve(ctx, host)
+	if verdict != Exists || probeHost == "" {
+		return verdict
+	}
+
+	wildcardAddrs, wildcardVerdict := r.Resolve(ctx, probeHost)
+	if wildcardVerdict != Exists {
+		return Exists
+	}
+
+	for _, addr := range addrs {
+		if !slices.Contains(wildcardAddrs, addr) {
+			return Exists
+		}
+	}
+
+	return Wildcard
+}
@@ -0,0 +1,184 @@
+package resolver
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// DNS response codes answered by the stub server
+const (
+	rcodeSuccess  = 0
+	rcodeServFail = 2
+	rcodeNXDomain = 3
+)
+
+// dnsServer is a minimal DNS server that answers A queries from a fixed set of records
+type dnsServer struct {
+	records   map[string][]string // A records by hostname
+	wildcards map[string][]string // A records of all other hostnames in a zone, by zone
+	failing   map[string]bool     // Hostnames answered with SERVFAIL
+	queries   atomic.Int64
+}
+
+// listen starts the server on a random local UDP port and returns its address
+func (s *dnsServer) listen(t *testing.T) string {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to start DNS server: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if response := s.answer(buf[:n]); response != nil {
+				_, _ = conn.WriteTo(response, addr)
+			}
+		}
+	}()
+
+	return conn.LocalAddr().String()
+}
+
+// answer builds the response to a query, or returns nil for a malformed query
+func (s *dnsServer) answer(query []byte) []byte {
+	if len(query) < 12 {
+		return nil
+	}
+
+	// Read the name of the (single) question
+	var labels []string
+	offset := 12
+	for offset < len(query) && query[offset] != 0 {
+		length := int(query[offset])
+		if offset+1+length > len(query) {
+			return nil
+		}
+		labels = append(labels, string(query[offset+1:offset+1+length]))
+		offset += 1 + length
+	}
+	end := offset + 5 // Terminating label, type and class
+	if end > len(query) {
+		return nil
+	}
+	s.queries.Add(1)
+
+	name := strings.ToLower(strings.Join(labels, "."))
+	qtype := binary.BigEndian.Uint16(query[offset+1:])
+
+	rcode := rcodeSuccess
+	addrs, ok := s.records[name]
+	if !ok {
+		addrs, ok = s.wildcard(name)
+	}
+	switch {
+	case s.failing[name]:
+		rcode, addrs = rcodeServFail, nil
+	case !ok:
+		rcode = rcodeNXDomain
+	case qtype != 1:
+		addrs = nil // Only A records are served
+	}
+
+	response := make([]byte, 12, 512)
+	copy(response, query[:2])                                      // ID
+	binary.BigEndian.PutUint16(response[2:], 0x8180|uint16(rcode)) // Response, recursion desired and available
+	binary.BigEndian.PutUint16(response[4:], 1)                    // Questions
+	binary.BigEndian.PutUint16(response[6:], uint16(len(addrs)))
+	response = append(response, query[12:end]...)
+
+	for _, addr := range addrs {
+		response = append(response, 0xc0, 12)    // Pointer to the question name
+		response = append(response, 0, 1, 0, 1)  // Type A, class IN
+		response = append(response, 0, 0, 0, 60) // TTL
+		response = append(response, 0, 4)        // Data length
+		response = append(response, net.ParseIP(addr).To4()...)
+	}
+
+	return response
+}
+
+// wildcard returns the wildcard records of the zone of a hostname
+func (s *dnsServer) wildcard(name string) ([]string, bool) {
+	for zone, addrs := range s.wildcards {
+		if strings.HasSuffix(name, "."+zone) {
+			return addrs, true
+		}
+	}
+	return nil, false
+}
+
+func TestCheck(t *testing.T) {
+	server := &dnsServer{
+		records: map[string][]string{
+			"acme.example.test":    {"192.0.2.1"},
+			"acme.wildcard.test":   {"192.0.2.10"},
+			"shared.wildcard.test": {"192.0.2.2", "192.0.2.3"},
+		},
+		wildcards: map[string][]string{
+			"wildcard.test": {"192.0.2.2", "192.0.2.3"},
+		},
+		failing: map[string]bool{
+			"broken.example.test": true,
+		},
+	}
+	r := New([]string{server.listen(t)}, 2*time.Second)
+
+	tests := []struct {
+		name      string
+		host      string
+		probeHost string
+		want      Verdict
+	}{
+		{"existing host", "acme.example.test", "probe.example.test", Exists},
+		{"existing host without probe", "acme.example.test", "", Exists},
+		{"non-existent host", "missing.example.test", "probe.example.test", NotExists},
+		{"case and trailing dot", "ACME.Example.test.", "probe.example.test", Exists},
+		{"specific record in wildcard zone", "acme.wildcard.test", "probe.wildcard.test", Exists},
+		{"wildcard record", "random.wildcard.test", "probe.wildcard.test", Wildcard},
+		{"explicit record equal to wildcard", "shared.wildcard.test", "probe.wildcard.test", Wildcard},
+		{"wildcard record without probe", "random.wildcard.test", "", Exists},
+		{"failing lookup", "broken.example.test", "probe.example.test", Unknown},
+		{"IP address", "192.0.2.1", "", Exists},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := r.Check(context.Background(), tt.host, tt.probeHost); got != tt.want {
+				t.Errorf("Check(%q, %q) = %v, want %v", tt.host, tt.probeHost, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveCache(t *testing.T) {
+	server := &dnsServer{
+		records: map[string][]string{
+			"acme.example.test": {"192.0.2.1"},
+		},
+	}
+	r := New([]string{server.listen(t)}, 2*time.Second)
+
+	for range 3 {
+		addrs, verdict := r.Resolve(context.Background(), "acme.example.test")
+		if verdict != Exists || len(addrs) != 1 || addrs[0] != "192.0.2.1" {
+			t.Fatalf("Resolve() = %v, %v, want [192.0.2.1], exists", addrs, verdict)
+		}
+	}
+
+	// A single lookup sends one A and one AAAA query
+	if queries := server.queries.Load(); queries != 2 {
+		t.Errorf("server received %d queries, want 2", queries)
+	}
+}
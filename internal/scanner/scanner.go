@@ -5,40 +5,77 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/url"
 	"os"
 	"regexp"
+	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/intigriti/misconfig-mapper/internal/checkpoint"
+	"github.com/intigriti/misconfig-mapper/internal/permutation"
+	"github.com/intigriti/misconfig-mapper/internal/resolver"
 	"github.com/intigriti/misconfig-mapper/internal/types"
 	"github.com/intigriti/misconfig-mapper/pkg/client"
 	"github.com/intigriti/misconfig-mapper/pkg/templates"
 	"golang.org/x/time/rate"
 )
 
-// Common domain suffixes for permutation generation
-// TODO: Add more suffixes
-var suffixes = []string{
-	"com", "net", "org", "io", "fr", "ltd", "app", "prod", "internal",
-	"dev", "development", "devops", "logs", "logging", "admin", "log",
-	"stage", "staging", "stg", "production", "dev-only", "cicd",
-	"employee-only", "testing", "secret", "kibana", "employees",
-	"partners", "sso", "saml", "tickets", "issues", "oauth2",
-}
+// schemePattern matches the HTTP(S) scheme of a target URL
+var schemePattern = regexp.MustCompile(`^https?://`)
 
 // Scanner manages the scanning process
 type Scanner struct {
 	Target           string
+	TargetList       string
 	AsDomain         bool
 	EnablePerms      bool
 	SkipChecks       bool
+	SkipDetection    bool
+	ReportDetected   bool
 	Client           *client.HTTPClient
 	JSONLines        bool
 	TerminalWidth    int
 	Verbosity        types.VerbosityLevel
 	RateLimiter      *rate.Limiter
+	Concurrency      int
 	SelectedServices []types.Service
+	Checkpoint       *checkpoint.Checkpoint
+	Permutations     *permutation.Engine
+	Filters          map[int64]*permutation.Filter
+	Resolver         *resolver.Resolver
+	Stats            Stats
+
+	mu     sync.Mutex    // Serializes output written by concurrent workers
+	output *bufio.Writer // Buffered standard output, flushed after every result
+}
+
+// Stats keeps track of the progress of a scan
+type Stats struct {
+	Targets    atomic.Int64 // Number of targets checked
+	Requests   atomic.Int64 // Number of requests sent
+	Detected   atomic.Int64 // Number of detected instances
+	Vulnerable atomic.Int64 // Number of vulnerable instances
+	Dropped    atomic.Int64 // Number of candidate names dropped by service permutation rules
+	Unresolved atomic.Int64 // Number of checks skipped because the host does not exist
+}
+
+// job represents a single service check against a single target
+type job struct {
+	service types.Service
+	target  string
+}
+
+// checkpointTarget returns the target under which the checks of a job are recorded in the checkpoint.
+// Variants of a service with different variable values are recorded separately.
+func (j job) checkpointTarget() string {
+	if variant := templates.Variant(j.service); variant != "" {
+		return fmt.Sprintf("%s [%s]", j.target, variant)
+	}
+	return j.target
 }
 
 // NewScanner creates a new scanner
@@ -52,6 +89,7 @@ func NewScanner(
 	width int,
 	verbosity types.VerbosityLevel,
 	delay int,
+	concurrency int,
 ) *Scanner {
 	// Create a rate limiter if delay is specified
 	var limiter *rate.Limiter
@@ -59,6 +97,11 @@ func NewScanner(
 		limiter = rate.NewLimiter(rate.Every(time.Duration(delay)*time.Millisecond), 1)
 	}
 
+	// Always run at least one worker
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
 	return &Scanner{
 		Target:        target,
 		AsDomain:      asDomain,
@@ -69,93 +112,223 @@ func NewScanner(
 		TerminalWidth: width,
 		Verbosity:     verbosity,
 		RateLimiter:   limiter,
+		Concurrency:   concurrency,
+		Permutations:  permutation.DefaultEngine(),
+		output:        bufio.NewWriter(os.Stdout),
 	}
 }
 
-// SetSelectedServices sets the services to scan
-func (s *Scanner) SetSelectedServices(services []types.Service) {
-	s.SelectedServices = services
-}
+// SetSelectedServices sets the services to scan and compiles their permutation rules
+func (s *Scanner) SetSelectedServices(services []types.Service) error {
+	filters := make(map[int64]*permutation.Filter)
 
-// GenerateTargets generates potential target domains based on the input
-func (s *Scanner) GenerateTargets() ([]string, error) {
-	var possibleTargets []string
+	for _, service := range services {
+		if service.PermutationRules == nil {
+			continue
+		}
 
-	// Check if target is a file
-	if templates.IsFile(s.Target) {
-		// Load targets from file
-		targets, err := s.loadTargetsFromFile(s.Target)
+		filter, err := permutation.NewFilter(*service.PermutationRules)
 		if err != nil {
-			return nil, err
+			return fmt.Errorf("invalid permutation rules of service %q: %w", service.Metadata.ServiceName, err)
 		}
+		filters[service.ID] = filter
+	}
 
-		if s.EnablePerms {
-			for _, target := range targets {
-				possibleTargets = append(possibleTargets, s.generatePermutations(target)...)
+	s.SelectedServices = services
+	s.Filters = filters
+
+	return nil
+}
+
+// SetSkipDetection sets whether the detection phase is skipped, checking every service for misconfigurations right away
+func (s *Scanner) SetSkipDetection(skip bool) {
+	s.SkipDetection = skip
+}
+
+// SetReportDetected reports instances that were detected but aren't vulnerable, including in JSON output
+func (s *Scanner) SetReportDetected(report bool) {
+	s.ReportDetected = report
+}
+
+// SetTargetList sets a file (or "-" for the standard input) to read additional targets from
+func (s *Scanner) SetTargetList(targetList string) {
+	s.TargetList = targetList
+}
+
+// SetPermutationEngine sets the engine used to generate permutations of each target
+func (s *Scanner) SetPermutationEngine(engine *permutation.Engine) {
+	s.Permutations = engine
+}
+
+// SetResolver sets the resolver used to skip hosts that don't exist before sending any request
+func (s *Scanner) SetResolver(r *resolver.Resolver) {
+	s.Resolver = r
+}
+
+// SetCheckpoint sets the checkpoint used to skip finished checks and record progress
+func (s *Scanner) SetCheckpoint(cp *checkpoint.Checkpoint) {
+	s.Checkpoint = cp
+}
+
+// Target is a target domain generated from the input
+type Target struct {
+	Name        string
+	Permutation bool // Generated from an input target, rather than the input target itself
+}
+
+// GenerateTargets streams the potential target domains based on the input.
+// Targets are read line by line from files or the standard input ("-"), so scanning starts before the whole list is read.
+// The target channel is closed once all targets are generated, after which the error channel reports any read error.
+func (s *Scanner) GenerateTargets(ctx context.Context) (<-chan Target, <-chan error) {
+	targets := make(chan Target)
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(errs)
+		defer close(targets)
+
+		// emit sends a target (and its permutations) to the scanner
+		emit := func(target string) bool {
+			possibleTargets := []string{target}
+			if s.EnablePerms {
+				possibleTargets = s.Permutations.Generate(target)
+			}
+
+			for _, possibleTarget := range possibleTargets {
+				select {
+				case targets <- Target{Name: possibleTarget, Permutation: possibleTarget != target}:
+				case <-ctx.Done():
+					return false
+				}
 			}
-		} else {
-			possibleTargets = targets
+			return true
 		}
-	} else {
-		// Generate targets from a single target
-		if s.EnablePerms {
-			possibleTargets = s.generatePermutations(s.Target)
-		} else {
-			possibleTargets = []string{s.Target}
+
+		if s.Target != "" {
+			var err error
+			switch {
+			case s.Target == "-":
+				// Read targets from the standard input
+				err = s.streamTargets(ctx, "-", emit)
+			case templates.IsFile(s.Target):
+				// Read targets from file, a target keyword could name a file by accident
+				if s.Verbosity >= types.Normal {
+					fmt.Fprintf(os.Stderr, "[+] Info: Reading targets from file %s\n", s.Target)
+				}
+				err = s.streamTargets(ctx, s.Target, emit)
+			default:
+				// Generate targets from a single target
+				emit(s.Target)
+			}
+			if err != nil {
+				errs <- err
+				return
+			}
 		}
-	}
 
-	if s.Verbosity >= types.Verbose {
-		fmt.Printf("[+] Checking %v possible target URLs...\n", len(possibleTargets))
-	}
+		if s.TargetList != "" {
+			if err := s.streamTargets(ctx, s.TargetList, emit); err != nil {
+				errs <- err
+			}
+		}
+	}()
 
-	return possibleTargets, nil
+	return targets, errs
 }
 
-// loadTargetsFromFile loads target domains from a file
-func (s *Scanner) loadTargetsFromFile(filePath string) ([]string, error) {
-	var targets []string
+// streamTargets reads targets line by line from a file (or the standard input for "-") and passes them to emit
+func (s *Scanner) streamTargets(ctx context.Context, filePath string, emit func(string) bool) error {
+	var r io.Reader = os.Stdin
 
-	file, err := os.Open(filePath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open file: %w", err)
+	if filePath != "-" {
+		file, err := os.Open(filePath)
+		if err != nil {
+			return fmt.Errorf("failed to open file: %w", err)
+		}
+		defer file.Close()
+
+		r = file
+	} else if s.Verbosity >= types.Verbose {
+		s.printf("[+] Reading targets from standard input...\n")
 	}
-	defer file.Close()
 
-	scanner := bufio.NewScanner(file)
+	scanner := bufio.NewScanner(r)
 	for scanner.Scan() {
 		line := strings.TrimSpace(scanner.Text())
-		if line != "" {
-			targets = append(targets, line)
+		if line == "" {
+			continue
+		}
+
+		if !emit(line) {
+			return ctx.Err()
 		}
 	}
 
 	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("failed to read file: %w", err)
+		return fmt.Errorf("failed to read targets: %w", err)
 	}
 
-	return targets, nil
+	return nil
 }
 
-// generatePermutations generates domain permutations for a target
-func (s *Scanner) generatePermutations(target string) []string {
-	var permutations []string
+// allows reports whether a target is a valid candidate name according to the permutation rules of a service.
+// Rules only drop generated permutations, the input targets themselves are always checked. Hostnames are
+// case-insensitive, so candidate names are checked in lowercase.
+func (s *Scanner) allows(service types.Service, target Target) bool {
+	filter, ok := s.Filters[service.ID]
 
-	// Always add original target
-	permutations = append(permutations, target)
+	// Rules only apply when the target is substituted into the template
+	if !ok || !target.Permutation || (s.AsDomain && !s.EnablePerms) {
+		return true
+	}
 
-	// Clean target
-	target = strings.TrimSpace(strings.ToLower(target))
+	return filter.Allows(strings.ToLower(schemePattern.ReplaceAllString(target.Name, "")))
+}
 
-	// Generate domain combinations
-	for _, suffix := range suffixes {
-		for _, connector := range []string{".", "-", ""} {
-			domain := fmt.Sprintf("%s%s%s", target, connector, suffix)
-			permutations = append(permutations, domain)
+// resolve pre-resolves the host a service check would be sent to.
+// Wildcard DNS records are detected by resolving a random label in place of the target.
+func (s *Scanner) resolve(ctx context.Context, j job) resolver.Verdict {
+	path := "/"
+	if len(j.service.Request.Path) > 0 {
+		path = j.service.Request.Path[0]
+	}
+
+	host := s.hostname(j.service.Request.BaseURL, path, j.target)
+	if host == "" {
+		return resolver.Unknown
+	}
+
+	var probeHost string
+	if s.EnablePerms || !s.AsDomain {
+		probeHost = s.hostname(j.service.Request.BaseURL, path, s.Resolver.ProbeLabel)
+
+		// The host doesn't depend on the target (e.g. groups.google.com), there's nothing to filter
+		if probeHost == host {
+			return resolver.Unknown
 		}
 	}
 
-	return permutations
+	verdict := s.Resolver.Check(ctx, host, probeHost)
+	if verdict == resolver.NotExists && s.Verbosity >= types.Verbose {
+		s.printf("[-] Skipping %s for %s (host %s does not exist)\n", j.service.Metadata.ServiceName, j.target, host)
+	}
+
+	return verdict
+}
+
+// hostname returns the hostname of the URL crafted for a target, or an empty string if it is invalid
+func (s *Scanner) hostname(baseURL, path, target string) string {
+	targetURL, err := s.craftTargetURL(baseURL, path, target)
+	if err != nil {
+		return ""
+	}
+
+	u, err := url.Parse(targetURL)
+	if err != nil {
+		return ""
+	}
+
+	return u.Hostname()
 }
 
 // craftTargetURL creates the full URL to test
@@ -164,7 +337,7 @@ func (s *Scanner) craftTargetURL(baseURL, path, domain string) (string, error) {
 
 	if s.EnablePerms || !s.AsDomain {
 		// Normalize domain (remove protocol)
-		domain = regexp.MustCompile(`^https?://`).ReplaceAllString(domain, "")
+		domain = schemePattern.ReplaceAllString(domain, "")
 		// Use the template's base URL with the target as a parameter
 		targetURL = strings.Replace(fmt.Sprintf("%v%v", baseURL, path), "{TARGET}", domain, -1)
 	} else {
@@ -185,82 +358,365 @@ func (s *Scanner) craftTargetURL(baseURL, path, domain string) (string, error) {
 	return targetURL, nil
 }
 
-// ScanTargets performs the scan operation across all services and targets
-func (s *Scanner) ScanTargets() error {
-	targets, err := s.GenerateTargets()
-	if err != nil {
-		return fmt.Errorf("failed to generate targets: %w", err)
+// ScanTargets performs the scan operation across all services and targets.
+// The scan stops as soon as the context is cancelled, in which case the results found so far are flushed and a summary is printed.
+func (s *Scanner) ScanTargets(ctx context.Context) error {
+	// Report the findings of the interrupted run again to keep the output complete
+	if s.Checkpoint != nil {
+		if s.Verbosity >= types.Verbose {
+			s.printf("[+] Resuming scan, skipping %d finished check(s)...\n", s.Checkpoint.Resumed())
+		}
+
+		for _, result := range s.Checkpoint.Findings() {
+			s.handleResult(&result)
+		}
 	}
 
-	for _, service := range s.SelectedServices {
-		for _, target := range targets {
-			for _, path := range service.Request.Path {
-				// Apply rate limiting if configured
-				if s.RateLimiter != nil {
-					_ = s.RateLimiter.Wait(context.Background())
-				}
+	jobs := make(chan job)
 
-				// Skip unnecessary paths for detection-only
-				if s.SkipChecks {
-					path = "/"
-				}
+	// Start the worker pool, the rate limiter is shared across all workers
+	var wg sync.WaitGroup
+	for range s.Concurrency {
+		wg.Go(func() {
+			for j := range jobs {
+				s.scanJob(ctx, j)
+			}
+		})
+	}
 
-				// Craft target URL
-				targetURL, err := s.craftTargetURL(service.Request.BaseURL, path, target)
-				if err != nil {
-					if s.Verbosity >= types.Verbose {
-						fmt.Fprintf(os.Stderr, "[-] Error: Failed to craft target URL %q: %v\n", target, err)
-					} else if s.Verbosity >= types.Normal {
-						fmt.Fprintf(os.Stderr, "[-] Error: Failed to craft target URL %q\n", target)
-					}
-					continue
-				}
+	targets, errs := s.GenerateTargets(ctx)
 
-				// Validate URL
-				parsedURL, err := url.Parse(targetURL)
-				if err != nil {
-					if s.Verbosity >= types.Verbose {
-						fmt.Fprintf(os.Stderr, "[-] Error: Invalid target URL %q: %v\n", targetURL, err)
-					} else if s.Verbosity >= types.Normal {
-						fmt.Fprintf(os.Stderr, "[-] Error: Invalid target URL %q\n", targetURL)
-					}
-					continue
-				}
+queue:
+	for target := range targets {
+		s.Stats.Targets.Add(1)
 
-				// Prepare result
-				result := types.Result{
-					URL:        parsedURL.String(),
-					ServiceId:  fmt.Sprintf("%d", service.ID),
-					Service:    service,
-					Exists:     false,
-					Vulnerable: false,
-				}
+		for _, service := range s.SelectedServices {
+			j := job{service: service, target: target.Name}
 
-				// Perform scan
-				s.Client.CheckResponse(&result, &service)
-
-				// Handle result
-				if result.Exists || result.Vulnerable {
-					s.handleResult(&result)
-					break // Found a result for this service, move to the next service
-				} else if s.Verbosity >= types.Verbose {
-					if s.SkipChecks {
-						fmt.Printf("[-] No %s instance found (%s)\n",
-							result.Service.Metadata.ServiceName, result.URL)
-					} else {
-						fmt.Printf("[-] No vulnerable %s instance found (%s)\n",
-							result.Service.Metadata.ServiceName, result.URL)
-					}
-				}
+			// Services that already yielded a result before the interruption are done
+			if s.Checkpoint != nil && s.Checkpoint.HasFinding(service.ID, j.checkpointTarget()) {
+				continue
+			}
+
+			// Drop candidate names the service can't have before any request goes out
+			if !s.allows(service, target) {
+				s.Stats.Dropped.Add(1)
+				continue
 			}
+
+			select {
+			case jobs <- j:
+			case <-ctx.Done():
+				break queue
+			}
+		}
+	}
+
+	close(jobs)
+	wg.Wait()
+
+	// Make sure all buffered results are written out, even if the scan was interrupted
+	defer s.flush()
+
+	// The target generator may still be blocked reading input when the scan is interrupted
+	if err := ctx.Err(); err != nil {
+		if s.Verbosity >= types.Normal {
+			fmt.Fprintf(os.Stderr, "\n[-] Warning: Scan interrupted, results may be incomplete!\n")
+			s.printSummary()
 		}
+		return err
+	}
+
+	if err := <-errs; err != nil {
+		return fmt.Errorf("failed to generate targets: %w", err)
+	}
+
+	if s.Verbosity >= types.Verbose {
+		s.printSummary()
 	}
 
 	return nil
 }
 
+// scanJob checks a service against a target in two phases. If the service declares a detection path, it first detects
+// whether an instance of the service exists, and only then checks the paths of the service for misconfigurations until
+// one of them yields a result. Without a detection path, the detection fingerprints are checked on the response of
+// each path instead, as the fingerprints may only be present on those paths.
+func (s *Scanner) scanJob(ctx context.Context, j job) {
+	service := j.service
+
+	// Skip hosts that don't exist before spending a request (and timeout) on them. Hosts that only match wildcard
+	// DNS records are still checked, as real tenants of zones that are wildcard by design resolve to the same addresses.
+	if s.Resolver != nil && s.resolve(ctx, j) == resolver.NotExists {
+		if ctx.Err() != nil {
+			return
+		}
+		s.Stats.Unresolved.Add(1)
+		s.markDone(j, service.Request.Path...)
+		return
+	}
+
+	// Skip services that were fully checked before the interruption
+	if s.Checkpoint != nil && !slices.ContainsFunc(service.Request.Path, func(path string) bool {
+		return !s.Checkpoint.IsDone(service.ID, j.checkpointTarget(), path)
+	}) {
+		return
+	}
+
+	// Detection phase
+	var (
+		detected      *types.Result
+		detectionPath string
+	)
+	if s.SkipChecks || (!s.SkipDetection && hasDetection(service) && service.Request.DetectionPath != "") {
+		path := service.Request.DetectionPath
+		if path == "" {
+			path = "/"
+		}
+
+		// The response of a detection path that is also checked for misconfigurations is only requested once
+		reuse := !s.SkipChecks && len(service.Request.Steps) == 0 && slices.Contains(service.Request.Path, path)
+		if reuse {
+			detectionPath = path
+		}
+
+		result := s.check(ctx, j, path, !reuse)
+		if result == nil {
+			return
+		}
+
+		if !result.Exists {
+			if s.Verbosity >= types.Verbose {
+				s.printf("[-] No %s instance found (%s)%s\n",
+					service.Metadata.ServiceName, result.URL, s.attempts(result))
+			}
+			s.markDone(j, service.Request.Path...)
+			return
+		}
+
+		if s.SkipChecks || result.Vulnerable {
+			s.addFinding(j, result)
+			s.handleResult(result)
+			return
+		}
+
+		if reuse {
+			s.markDone(j, detectionPath)
+			if s.Verbosity >= types.Verbose {
+				s.printf("[-] No vulnerable %s instance found (%s)%s\n",
+					service.Metadata.ServiceName, result.URL, s.attempts(result))
+			}
+		}
+
+		detected = result
+	}
+
+	// Vulnerability phase
+	for _, path := range service.Request.Path {
+		// Skip checks that were finished before the interruption or during detection
+		if path == detectionPath || (s.Checkpoint != nil && s.Checkpoint.IsDone(service.ID, j.checkpointTarget(), path)) {
+			continue
+		}
+
+		result := s.check(ctx, j, path, false)
+		if result == nil {
+			if ctx.Err() != nil {
+				return
+			}
+			continue
+		}
+
+		if result.Vulnerable {
+			s.addFinding(j, result)
+			s.handleResult(result)
+			return // Found a result for this service, skip the remaining paths
+		}
+
+		// Without a detection phase, the first path with the detection fingerprints reveals the instance
+		if result.Exists && detected == nil && !s.SkipDetection {
+			detected = result
+		}
+
+		s.markDone(j, path)
+
+		if s.Verbosity >= types.Verbose {
+			// Point out flaky targets so they can be told apart from clean negatives
+			s.printf("[-] No vulnerable %s instance found (%s)%s\n",
+				service.Metadata.ServiceName, result.URL, s.attempts(result))
+		}
+	}
+
+	// Report instances that exist but aren't vulnerable
+	if detected != nil {
+		s.addFinding(j, detected)
+		s.handleResult(detected)
+	}
+}
+
+// check crafts the URL of a path of a service for a target and checks it.
+// It returns nil if the scan was cancelled or the URL is invalid.
+func (s *Scanner) check(ctx context.Context, j job, path string, detectionOnly bool) *types.Result {
+	service := j.service
+
+	// Apply rate limiting if configured
+	if s.RateLimiter != nil {
+		if err := s.RateLimiter.Wait(ctx); err != nil {
+			return nil
+		}
+	}
+
+	// Stop checking once the scan has been cancelled
+	if ctx.Err() != nil {
+		return nil
+	}
+
+	// Craft target URL
+	targetURL, err := s.craftTargetURL(service.Request.BaseURL, path, j.target)
+	if err != nil {
+		if s.Verbosity >= types.Verbose {
+			fmt.Fprintf(os.Stderr, "[-] Error: Failed to craft target URL %q: %v\n", j.target, err)
+		} else if s.Verbosity >= types.Normal {
+			fmt.Fprintf(os.Stderr, "[-] Error: Failed to craft target URL %q\n", j.target)
+		}
+		return nil
+	}
+
+	// Validate URL
+	parsedURL, err := url.Parse(targetURL)
+	if err != nil {
+		if s.Verbosity >= types.Verbose {
+			fmt.Fprintf(os.Stderr, "[-] Error: Invalid target URL %q: %v\n", targetURL, err)
+		} else if s.Verbosity >= types.Normal {
+			fmt.Fprintf(os.Stderr, "[-] Error: Invalid target URL %q\n", targetURL)
+		}
+		return nil
+	}
+
+	// Prepare result
+	result := types.Result{
+		URL:        parsedURL.String(),
+		Target:     j.target,
+		ServiceId:  fmt.Sprintf("%d", service.ID),
+		Service:    service,
+		Exists:     false,
+		Vulnerable: false,
+	}
+
+	// Perform scan
+	if detectionOnly {
+		s.Client.CheckDetection(ctx, &result, &service)
+	} else {
+		s.Client.CheckResponse(ctx, &result, &service)
+	}
+	s.Stats.Requests.Add(int64(result.Attempts))
+
+	// Discard results of requests that were aborted by the cancellation
+	if ctx.Err() != nil {
+		return nil
+	}
+
+	return &result
+}
+
+// hasDetection reports whether a service defines how to detect its instances
+func hasDetection(service types.Service) bool {
+	return service.Response.DetectionMatchers != nil || len(service.Response.DetectionFingerprints) > 0
+}
+
+// attempts describes how many attempts a request took if it had to be retried
+func (s *Scanner) attempts(result *types.Result) string {
+	if result.Attempts > max(1, len(result.Service.Request.Steps)) {
+		return fmt.Sprintf(" after %d attempts", result.Attempts)
+	}
+	return ""
+}
+
+// markDone records checked paths so an interrupted scan can be resumed
+func (s *Scanner) markDone(j job, paths ...string) {
+	if s.Checkpoint == nil {
+		return
+	}
+
+	for _, path := range paths {
+		if err := s.Checkpoint.MarkDone(j.service.ID, j.checkpointTarget(), path); err != nil {
+			fmt.Fprintf(os.Stderr, "[-] Error: %v\n", err)
+		}
+	}
+}
+
+// addFinding records a result so it is reported again when an interrupted scan is resumed
+func (s *Scanner) addFinding(j job, result *types.Result) {
+	if s.Checkpoint == nil {
+		return
+	}
+
+	if err := s.Checkpoint.AddFinding(j.service.ID, j.checkpointTarget(), *result); err != nil {
+		fmt.Fprintf(os.Stderr, "[-] Error: %v\n", err)
+	}
+}
+
+// printSummary prints an overview of what was checked during the scan
+func (s *Scanner) printSummary() {
+	fmt.Fprintf(os.Stderr, "[+] Summary: Checked %d target(s) against %d service(s) with %d request(s)\n",
+		s.Stats.Targets.Load(), len(s.SelectedServices), s.Stats.Requests.Load())
+
+	if unresolved := s.Stats.Unresolved.Load(); unresolved > 0 {
+		fmt.Fprintf(os.Stderr, "[+] Summary: %d check(s) skipped because the host does not exist\n", unresolved)
+	}
+
+	if dropped := s.Stats.Dropped.Load(); dropped > 0 {
+		fmt.Fprintf(os.Stderr, "[+] Summary: %d candidate name(s) skipped by service permutation rules\n", dropped)
+	}
+
+	if s.SkipChecks || !s.SkipDetection {
+		fmt.Fprintf(os.Stderr, "[+] Summary: %d instance(s) detected\n", s.Stats.Detected.Load())
+	}
+	if !s.SkipChecks {
+		fmt.Fprintf(os.Stderr, "[+] Summary: %d vulnerable instance(s) found\n", s.Stats.Vulnerable.Load())
+	}
+}
+
+// printf writes a formatted message to the (buffered) standard output
+func (s *Scanner) printf(format string, a ...any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fmt.Fprintf(s.output, format, a...)
+	_ = s.output.Flush()
+}
+
+// flush writes any buffered output to the standard output
+func (s *Scanner) flush() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.output.Flush(); err != nil {
+		fmt.Fprintf(os.Stderr, "[-] Error: Failed to write output: %v\n", err)
+	}
+}
+
 // handleResult processes and displays a scan result
 func (s *Scanner) handleResult(result *types.Result) {
+	if result.Vulnerable {
+		s.Stats.Vulnerable.Add(1)
+	}
+	if result.Exists {
+		s.Stats.Detected.Add(1)
+	}
+
+	// Instances that exist but aren't vulnerable are only reported in verbose mode or when requested,
+	// JSON output only contains them when requested
+	detectedOnly := !s.SkipChecks && !result.Vulnerable
+	if detectedOnly && !s.ReportDetected && (s.JSONLines || s.Verbosity < types.Verbose) {
+		return
+	}
+
+	// Prevent results of concurrent workers from interleaving
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	w := s.output
+
 	// Output as JSON if requested
 	if s.JSONLines {
 		d, err := json.Marshal(result)
@@ -268,36 +724,60 @@ func (s *Scanner) handleResult(result *types.Result) {
 			fmt.Fprintf(os.Stderr, "failed to marshal result %v", err)
 			return
 		}
-		fmt.Println(string(d))
+		fmt.Fprintln(w, string(d))
+
+		// Flush every line so that results can be streamed to other tools while the scan runs
+		if err := w.Flush(); err != nil {
+			fmt.Fprintf(os.Stderr, "[-] Error: Failed to write output: %v\n", err)
+		}
 		return
 	}
 
 	// Output in human-readable format
-	fmt.Println(strings.Repeat("-", s.TerminalWidth))
+	if detectedOnly {
+		fmt.Fprintf(w, "[+] %s instance detected, but not vulnerable (%s)\n", result.Service.Metadata.ServiceName, result.URL)
+		_ = w.Flush()
+		return
+	}
+
+	fmt.Fprintln(w, strings.Repeat("-", s.TerminalWidth))
 
 	if s.SkipChecks {
-		fmt.Printf("[+] 1 %s detected!\n", result.Service.Metadata.ServiceName)
+		fmt.Fprintf(w, "[+] 1 %s detected!\n", result.Service.Metadata.ServiceName)
 	} else {
-		fmt.Println("[+] 1 Vulnerable result found!")
+		fmt.Fprintln(w, "[+] 1 Vulnerable result found!")
+	}
+
+	fmt.Fprintf(w, "URL: %s\n", result.URL)
+	fmt.Fprintf(w, "Service: %s\n", result.Service.Metadata.ServiceName)
+	fmt.Fprintf(w, "Description: %s\n", result.Service.Metadata.Description)
+
+	// Only report attempts when requests had to be retried
+	if result.Attempts > max(1, len(result.Service.Request.Steps)) {
+		fmt.Fprintf(w, "Attempts: %d\n", result.Attempts)
 	}
 
-	fmt.Printf("URL: %s\n", result.URL)
-	fmt.Printf("Service: %s\n", result.Service.Metadata.ServiceName)
-	fmt.Printf("Description: %s\n", result.Service.Metadata.Description)
+	if len(result.Evidence) > 0 {
+		fmt.Fprintln(w, "\nEvidence:")
+		for _, evidence := range result.Evidence {
+			fmt.Fprintf(w, "\t- %s: %s\n", evidence.Name, strings.Join(evidence.Values, ", "))
+		}
+	}
 
 	if !s.SkipChecks && len(result.Service.Metadata.ReproductionSteps) > 0 {
-		fmt.Println("\nReproduction Steps:")
+		fmt.Fprintln(w, "\nReproduction Steps:")
 		for _, step := range result.Service.Metadata.ReproductionSteps {
-			fmt.Printf("\t- %s\n", step)
+			fmt.Fprintf(w, "\t- %s\n", step)
 		}
 	}
 
 	if len(result.Service.Metadata.References) > 0 {
-		fmt.Println("\nReferences:")
+		fmt.Fprintln(w, "\nReferences:")
 		for _, ref := range result.Service.Metadata.References {
-			fmt.Printf("\t- %s\n", ref)
+			fmt.Fprintf(w, "\t- %s\n", ref)
 		}
 	}
 
-	fmt.Println(strings.Repeat("-", s.TerminalWidth))
+	fmt.Fprintln(w, strings.Repeat("-", s.TerminalWidth))
+	_ = w.Flush()
 }
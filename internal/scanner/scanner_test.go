@@ -0,0 +1,383 @@
+package scanner
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/intigriti/misconfig-mapper/internal/permutation"
+	"github.com/intigriti/misconfig-mapper/internal/types"
+	"github.com/intigriti/misconfig-mapper/pkg/client"
+)
+
+// testServer records the paths it receives and answers them with a handler
+type testServer struct {
+	*httptest.Server
+
+	mu    sync.Mutex
+	paths []string
+}
+
+// newTestServer starts a server that answers every request with the handler
+func newTestServer(t *testing.T, handler http.HandlerFunc) *testServer {
+	t.Helper()
+
+	s := &testServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.paths = append(s.paths, r.URL.Path)
+		s.mu.Unlock()
+
+		handler(w, r)
+	}))
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+// Paths returns the sorted paths the server received
+func (s *testServer) Paths() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return slices.Sorted(slices.Values(s.paths))
+}
+
+// testService returns a service that substitutes the target into the first path segment of the server URL
+func testService(id int64, server *testServer, paths []string, fingerprints []string) types.Service {
+	var service types.Service
+	service.ID = id
+	service.Metadata.ServiceName = "Test Service"
+	service.Request.Method = "GET"
+	service.Request.BaseURL = server.URL + "/{TARGET}"
+	service.Request.Path = paths
+	service.Response.Fingerprints = fingerprints
+	return service
+}
+
+// newTestScanner creates a scanner that writes its results as JSON to a buffer
+func newTestScanner(t *testing.T, target string, concurrency int, services ...types.Service) (*Scanner, *bytes.Buffer) {
+	t.Helper()
+
+	httpClient := client.NewHTTPClient(5000, 0, nil, false, types.Silent, true)
+	t.Cleanup(httpClient.Client.CloseIdleConnections)
+
+	s := NewScanner(target, false, false, false, httpClient, true, 80, types.Silent, 0, concurrency)
+	if err := s.SetSelectedServices(services); err != nil {
+		t.Fatalf("SetSelectedServices() error = %v", err)
+	}
+
+	var out bytes.Buffer
+	s.output = bufio.NewWriter(&out)
+
+	return s, &out
+}
+
+// results decodes the JSON results written by a scanner
+func results(t *testing.T, out *bytes.Buffer) []types.Result {
+	t.Helper()
+
+	var results []types.Result
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		if line == "" {
+			continue
+		}
+
+		var result types.Result
+		if err := json.Unmarshal([]byte(line), &result); err != nil {
+			t.Fatalf("invalid result %q: %v", line, err)
+		}
+		results = append(results, result)
+	}
+
+	return results
+}
+
+// targetList writes a target list file with the targets acme0 to acme<n-1>
+func targetList(t *testing.T, n int) string {
+	t.Helper()
+
+	var targets strings.Builder
+	for i := range n {
+		fmt.Fprintf(&targets, "acme%d\n", i)
+	}
+
+	path := filepath.Join(t.TempDir(), "targets.txt")
+	if err := os.WriteFile(path, []byte(targets.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+// inFlight tracks the number of concurrent requests a server handles
+type inFlight struct {
+	current atomic.Int64
+	max     atomic.Int64
+}
+
+// start records the start of a request and returns a function that records its end
+func (f *inFlight) start() func() {
+	n := f.current.Add(1)
+	for {
+		m := f.max.Load()
+		if n <= m || f.max.CompareAndSwap(m, n) {
+			break
+		}
+	}
+
+	return func() { f.current.Add(-1) }
+}
+
+func TestScanTargetsConcurrently(t *testing.T) {
+	const (
+		targets     = 25
+		concurrency = 8
+	)
+
+	var requests inFlight
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		defer requests.start()()
+		time.Sleep(5 * time.Millisecond)
+
+		// Every target is vulnerable on the second path of each service
+		if strings.HasSuffix(r.URL.Path, "/b") {
+			_, _ = w.Write([]byte("vulnerable"))
+		}
+	})
+
+	first := testService(1, server, []string{"/1/a", "/1/b", "/1/c"}, []string{"vulnerable"})
+	second := testService(2, server, []string{"/2/a", "/2/b", "/2/c"}, []string{"vulnerable"})
+
+	s, out := newTestScanner(t, "", concurrency, first, second)
+	s.SetTargetList(targetList(t, targets))
+
+	if err := s.ScanTargets(context.Background()); err != nil {
+		t.Fatalf("ScanTargets() error = %v", err)
+	}
+
+	// Each service reports one result per target
+	seen := make(map[string]bool)
+	for _, result := range results(t, out) {
+		key := result.ServiceId + " " + result.Target
+		if seen[key] {
+			t.Errorf("service %s reported %s more than once", result.ServiceId, result.Target)
+		}
+		seen[key] = true
+
+		if !result.Vulnerable || !strings.HasSuffix(result.URL, "/b") {
+			t.Errorf("unexpected result %s (vulnerable: %v)", result.URL, result.Vulnerable)
+		}
+	}
+	if len(seen) != 2*targets {
+		t.Errorf("found %d result(s), want %d", len(seen), 2*targets)
+	}
+
+	// The remaining paths of a service are skipped once it found a result for a target
+	counts := make(map[string]int)
+	for _, path := range server.Paths() {
+		counts[path[strings.LastIndex(path, "/"):]]++
+	}
+	if counts["/a"] != 2*targets || counts["/b"] != 2*targets || counts["/c"] != 0 {
+		t.Errorf("requested /a %d, /b %d and /c %d time(s), want %d, %d and 0", counts["/a"], counts["/b"], counts["/c"], 2*targets, 2*targets)
+	}
+	if got := s.Stats.Targets.Load(); got != targets {
+		t.Errorf("Stats.Targets = %d, want %d", got, targets)
+	}
+
+	if got := requests.max.Load(); got < 2 || got > concurrency {
+		t.Errorf("server handled up to %d concurrent request(s), want between 2 and %d", got, concurrency)
+	}
+}
+
+func TestScanTargetsCancel(t *testing.T) {
+	const concurrency = 4
+
+	var requests inFlight
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		defer requests.start()()
+
+		// Hang until the scan gives up on the request
+		<-r.Context().Done()
+	})
+
+	s, _ := newTestScanner(t, "", concurrency, testService(1, server, []string{"/"}, []string{"vulnerable"}))
+	s.SetTargetList(targetList(t, 1000))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- s.ScanTargets(ctx) }()
+
+	// Wait for all workers to be busy
+	deadline := time.Now().Add(5 * time.Second)
+	for requests.current.Load() < concurrency {
+		if time.Now().After(deadline) {
+			t.Fatalf("only %d of %d workers sent a request", requests.current.Load(), concurrency)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("ScanTargets() error = %v, want %v", err, context.Canceled)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("ScanTargets() did not return after the scan was cancelled")
+	}
+
+	// No requests are sent after the workers stopped
+	sent := len(server.Paths())
+	time.Sleep(50 * time.Millisecond)
+	if got := len(server.Paths()); got != sent || sent > concurrency {
+		t.Errorf("server received %d request(s), then %d, want at most %d", sent, got, concurrency)
+	}
+	if got := requests.max.Load(); got > concurrency {
+		t.Errorf("server handled %d concurrent requests, want at most %d", got, concurrency)
+	}
+}
+
+func TestPermutationRules(t *testing.T) {
+	profile := permutation.Profile{
+		Name:       "test",
+		Separators: []string{"-", "."},
+		Wordlists:  map[string][]string{"env": {"dev"}},
+		Patterns:   []string{"{target}{sep}{env}"},
+	}
+
+	tests := []struct {
+		name         string
+		target       string
+		permutations bool
+		want         []string
+	}{
+		{"mixed-case target", "Intigriti", false, []string{"/Intigriti/"}},
+		{"target with disallowed characters", "acme.corp", false, []string{"/acme.corp/"}},
+		{"mixed-case permutations", "Intigriti", true, []string{"/Intigriti/", "/intigriti-dev/", "/intigriti/"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {})
+
+			service := testService(0, server, []string{"/"}, []string{"vulnerable"})
+			service.PermutationRules = &types.PermutationRules{AllowedCharacters: "a-z0-9-"}
+
+			s, _ := newTestScanner(t, tt.target, 1, service)
+			s.EnablePerms = tt.permutations
+
+			engine, err := permutation.NewEngine(profile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			s.SetPermutationEngine(engine)
+
+			if err := s.ScanTargets(context.Background()); err != nil {
+				t.Fatalf("ScanTargets() error = %v", err)
+			}
+
+			if got := server.Paths(); !slices.Equal(got, tt.want) {
+				t.Errorf("requested %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetection(t *testing.T) {
+	tests := []struct {
+		name          string
+		detectionPath string
+		pages         map[string]string // Response bodies by path, all other paths are empty
+		wantPaths     []string
+		wantResults   []string
+	}{
+		{
+			name:        "ungated vulnerable on the second path",
+			pages:       map[string]string{"/acme/jenkins/signup": "Sign in - Create an account"},
+			wantPaths:   []string{"/acme/jenkins/signup", "/acme/signup"},
+			wantResults: []string{"vulnerable /acme/jenkins/signup"},
+		},
+		{
+			name:        "ungated detected on a path",
+			pages:       map[string]string{"/acme/signup": "Sign in"},
+			wantPaths:   []string{"/acme/jenkins/signup", "/acme/signup"},
+			wantResults: []string{"detected /acme/signup"},
+		},
+		{
+			name:        "ungated without an instance",
+			pages:       map[string]string{"/acme/": "Sign in - Create an account"},
+			wantPaths:   []string{"/acme/jenkins/signup", "/acme/signup"},
+			wantResults: nil,
+		},
+		{
+			name:          "gated without an instance",
+			detectionPath: "/login",
+			pages:         map[string]string{"/acme/signup": "Sign in - Create an account"},
+			wantPaths:     []string{"/acme/login"},
+			wantResults:   nil,
+		},
+		{
+			name:          "gated vulnerable",
+			detectionPath: "/login",
+			pages:         map[string]string{"/acme/login": "Sign in", "/acme/signup": "Sign in - Create an account"},
+			wantPaths:     []string{"/acme/login", "/acme/signup"},
+			wantResults:   []string{"vulnerable /acme/signup"},
+		},
+		{
+			name:          "gated detected",
+			detectionPath: "/login",
+			pages:         map[string]string{"/acme/login": "Sign in"},
+			wantPaths:     []string{"/acme/jenkins/signup", "/acme/login", "/acme/signup"},
+			wantResults:   []string{"detected /acme/login"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte(tt.pages[r.URL.Path]))
+			})
+
+			service := testService(5, server, []string{"/signup", "/jenkins/signup"}, []string{"Create an account"})
+			service.Request.DetectionPath = tt.detectionPath
+			service.Response.DetectionFingerprints = []string{"Sign in"}
+
+			s, out := newTestScanner(t, "acme", 1, service)
+			s.SetReportDetected(true)
+
+			if err := s.ScanTargets(context.Background()); err != nil {
+				t.Fatalf("ScanTargets() error = %v", err)
+			}
+
+			if got := server.Paths(); !slices.Equal(got, tt.wantPaths) {
+				t.Errorf("requested %v, want %v", got, tt.wantPaths)
+			}
+
+			var got []string
+			for _, result := range results(t, out) {
+				status := "detected"
+				if result.Vulnerable {
+					status = "vulnerable"
+				}
+				got = append(got, status+" "+strings.TrimPrefix(result.URL, server.URL))
+			}
+			if !slices.Equal(got, tt.wantResults) {
+				t.Errorf("results %v, want %v", got, tt.wantResults)
+			}
+		})
+	}
+}
@@ -0,0 +1,187 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+
+	"github.com/intigriti/misconfig-mapper/internal/config"
+	"github.com/intigriti/misconfig-mapper/internal/types"
+	"github.com/intigriti/misconfig-mapper/pkg/client"
+	"github.com/intigriti/misconfig-mapper/pkg/matchers"
+	"github.com/intigriti/misconfig-mapper/pkg/templates"
+)
+
+// fixtureTimeout is the timeout in milliseconds of a replayed fixture request
+const fixtureTimeout = 5000
+
+// replayTransport sends every request to a local test server, regardless of the host of the request
+type replayTransport struct {
+	server *url.URL
+	next   http.RoundTripper
+}
+
+// RoundTrip rewrites the request to the test server and keeps the original host in the Host header
+func (t *replayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Host = req.URL.Host
+	req.URL.Scheme = t.server.Scheme
+	req.URL.Host = t.server.Host
+
+	return t.next.RoundTrip(req)
+}
+
+// testTemplates replays the recorded fixtures of each template through the matching logic of the HTTP client
+func testTemplates(ctx context.Context, cmd *config.TemplatesCommand) error {
+	manager := newTemplatesManager(cmd.Paths, cmd.Verbosity)
+
+	services, err := manager.LoadTemplates()
+	if err != nil {
+		return fmt.Errorf("failed to load services: %w", err)
+	}
+
+	selected := manager.GetService(cmd.ServiceID, services)
+	if len(selected) == 0 {
+		return fmt.Errorf("service ID %q does not match any template", cmd.ServiceID)
+	}
+
+	fixtures, err := manager.LoadFixtures()
+	if err != nil {
+		return fmt.Errorf("failed to load fixtures: %w", err)
+	}
+
+	var tested, failed, total int
+	for _, service := range selected {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if len(fixtures[service.ID]) == 0 {
+			if cmd.Verbosity >= types.Verbose {
+				fmt.Printf("[-] Info: Service %d (%s) has no fixtures\n", service.ID, service.Metadata.ServiceName)
+			}
+			continue
+		}
+
+		var failures []string
+		for _, fixture := range fixtures[service.ID] {
+			outcome, err := replayFixture(ctx, service, fixture, cmd.Verbosity)
+			if err != nil {
+				failures = append(failures, fmt.Sprintf("%s: %v (%s)", fixture.Name, err, fixture.File))
+			} else if outcome != fixture.Expect {
+				failures = append(failures, fmt.Sprintf("%s: expected %s %s, got %s (%s)",
+					fixture.Name, fixture.Phase, fixture.Expect, outcome, fixture.File))
+			}
+		}
+
+		tested++
+		total += len(fixtures[service.ID])
+
+		if len(failures) > 0 {
+			failed++
+			fmt.Printf("[-] FAIL %d (%s): %d/%d fixture(s) failed\n",
+				service.ID, service.Metadata.ServiceName, len(failures), len(fixtures[service.ID]))
+			for _, failure := range failures {
+				fmt.Printf("    - %s\n", failure)
+			}
+		} else if cmd.Verbosity >= types.Normal {
+			fmt.Printf("[+] PASS %d (%s): %d fixture(s)\n",
+				service.ID, service.Metadata.ServiceName, len(fixtures[service.ID]))
+		}
+	}
+
+	if tested == 0 {
+		return fmt.Errorf("no fixtures found for the selected templates")
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d template(s) failed their fixtures", failed, tested)
+	}
+
+	if cmd.Verbosity >= types.Normal {
+		fmt.Printf("[+] Info: %d template(s) passed all %d fixture(s)!\n", tested, total)
+	}
+
+	return nil
+}
+
+// replayFixture serves the recorded response of a fixture from a local test server, checks it like a scan would
+// and returns the outcome: positive, negative or excluded
+func replayFixture(ctx context.Context, service types.Service, fixture templates.Fixture, verbosity types.VerbosityLevel) (string, error) {
+	// Replay the variant of the service with the variable values of the fixture
+	overrides := make(map[string][]string, len(fixture.Variables))
+	for name, value := range fixture.Variables {
+		overrides[name] = []string{value}
+	}
+	variants, _ := templates.ExpandVariables([]types.Service{service}, overrides)
+	service = variants[0]
+
+	compiled, err := matchers.CompileService(&service)
+	if err != nil {
+		return "", fmt.Errorf("invalid template: %w", err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for key, value := range fixture.Response.Headers {
+			w.Header().Set(key, value)
+		}
+		w.WriteHeader(fixture.Response.StatusCode)
+		fmt.Fprint(w, fixture.Response.Body)
+	}))
+	defer server.Close()
+
+	serverURL, err := url.Parse(server.URL)
+	if err != nil {
+		return "", err
+	}
+
+	detectionOnly := fixture.Phase == templates.PhaseDetection
+
+	httpClient := client.NewHTTPClient(fixtureTimeout, 10, nil, detectionOnly, verbosity, true)
+	httpClient.SetRetryPolicy(client.RetryPolicy{MaxAttempts: 1})
+	httpClient.Client.Transport = &replayTransport{
+		server: serverURL,
+		next:   httpClient.Client.Transport,
+	}
+
+	path := fixture.Path
+	if path == "" && detectionOnly {
+		path = service.Request.DetectionPath
+		if path == "" {
+			path = "/"
+		}
+	} else if path == "" && len(service.Request.Path) > 0 {
+		path = service.Request.Path[0]
+	}
+
+	result := types.Result{
+		URL:       strings.ReplaceAll(service.Request.BaseURL+path, "{TARGET}", fixture.Target),
+		Target:    fixture.Target,
+		ServiceId: fmt.Sprintf("%d", service.ID),
+		Service:   service,
+	}
+
+	if detectionOnly {
+		httpClient.CheckDetection(ctx, &result, &service)
+	} else {
+		httpClient.CheckResponse(ctx, &result, &service)
+	}
+	if ctx.Err() != nil {
+		return "", ctx.Err()
+	}
+	if result.Attempts == 0 {
+		return "", fmt.Errorf("request to %s was not sent", result.URL)
+	}
+
+	switch {
+	case detectionOnly && result.Exists, !detectionOnly && result.Vulnerable:
+		return templates.ExpectPositive, nil
+	case compiled.Exclusion != nil && compiled.Exclusion.MatchString(fixture.Response.Body):
+		return templates.ExpectExcluded, nil
+	default:
+		return templates.ExpectNegative, nil
+	}
+}
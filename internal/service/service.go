@@ -1,18 +1,29 @@
 package service
 
 import (
+	"context"
+	"crypto/ed25519"
 	"fmt"
 	"os"
-	"path/filepath"
+	"slices"
+	"time"
 
+	"github.com/intigriti/misconfig-mapper/internal/checkpoint"
 	"github.com/intigriti/misconfig-mapper/internal/config"
+	"github.com/intigriti/misconfig-mapper/internal/permutation"
+	"github.com/intigriti/misconfig-mapper/internal/resolver"
 	"github.com/intigriti/misconfig-mapper/internal/scanner"
 	"github.com/intigriti/misconfig-mapper/internal/types"
 	"github.com/intigriti/misconfig-mapper/pkg/client"
+	"github.com/intigriti/misconfig-mapper/pkg/ratelimit"
 	"github.com/intigriti/misconfig-mapper/pkg/templates"
+	defaults "github.com/intigriti/misconfig-mapper/templates"
 	"golang.org/x/term"
 )
 
+// dnsTimeout is the timeout of a single DNS lookup made by the DNS filter
+const dnsTimeout = 3 * time.Second
+
 // MisconfigMapper represents the application service
 type MisconfigMapper struct {
 	Config    *config.Config
@@ -23,10 +34,17 @@ type MisconfigMapper struct {
 func NewMisconfigMapper(cfg *config.Config) *MisconfigMapper {
 	return &MisconfigMapper{
 		Config:    cfg,
-		Templates: templates.NewManager(cfg.TemplatesPath, cfg.Verbosity),
+		Templates: newTemplatesManager(cfg.TemplatesPaths, cfg.Verbosity),
 	}
 }
 
+// newTemplatesManager creates a template manager that falls back to the embedded templates until templates are installed
+func newTemplatesManager(templatesDirs []string, verbosity types.VerbosityLevel) *templates.Manager {
+	manager := templates.NewManager(templatesDirs, verbosity)
+	manager.SetEmbedded(defaults.Bundle)
+	return manager
+}
+
 // GetTerminalWidth returns the width of the terminal
 func (m *MisconfigMapper) GetTerminalWidth() int {
 	fd := int(os.Stdout.Fd())
@@ -37,61 +55,105 @@ func (m *MisconfigMapper) GetTerminalWidth() int {
 	return width
 }
 
-// Run executes the main application logic
-func (m *MisconfigMapper) Run() error {
+// Run executes the main application logic until it completes or the context is cancelled
+func (m *MisconfigMapper) Run(ctx context.Context) error {
 	termWidth := m.GetTerminalWidth()
 
+	// Configure where templates are updated from
+	m.Templates.SetSource(m.Config.TemplatesSource)
+	m.Templates.SetSkipVerify(m.Config.SkipVerify)
+
+	// Template updates must be signed with the key of the official templates, unless another key is specified
+	var key ed25519.PublicKey
+	var err error
+	if len(defaults.PublicKey) > 0 {
+		if key, err = templates.ParsePublicKey(defaults.PublicKey); err != nil {
+			return fmt.Errorf("invalid embedded public key: %w", err)
+		}
+	}
+	if m.Config.TemplatesPubKey != "" {
+		if key, err = templates.LoadPublicKey(m.Config.TemplatesPubKey); err != nil {
+			return err
+		}
+	}
+	m.Templates.SetPublicKey(key)
+
 	// Update templates if requested
 	if m.Config.UpdateTemplates {
-		serviceFilePath := filepath.Join(m.Config.TemplatesPath, "services.json")
-		fileExists := templates.IsFile(serviceFilePath)
-		if err := m.Templates.UpdateTemplates(fileExists); err != nil {
+		diff, err := m.Templates.UpdateTemplates(ctx, m.Config.DryRun)
+		if err != nil {
 			return fmt.Errorf("failed to update templates: %w", err)
 		}
+
+		// Report which services changed, so earlier results can be rescanned
+		if diff != nil {
+			m.Templates.PrintDiff(diff, m.Config.JSONLines)
+		}
+		if m.Config.DryRun && diff != nil && m.Config.Verbosity >= types.Normal {
+			fmt.Fprintln(os.Stderr, "[+] Info: Dry run, the templates were not updated")
+		}
 		return nil
 	}
 
 	// Load templates
 	services, err := m.Templates.LoadTemplates()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "[-] Error: Failed to load services!\n")
-
-		// Try to update templates
-		if err := m.Templates.UpdateTemplates(false); err != nil {
-			return fmt.Errorf("failed to pull latest services: %w", err)
-		}
+		return fmt.Errorf("failed to load services: %w", err)
+	}
 
-		// Reload templates
-		services, err = m.Templates.LoadTemplates()
-		if err != nil {
-			return fmt.Errorf("failed to load services: %w", err)
-		}
+	// Select services by ID, name, tags and severity
+	filter, err := templates.NewFilter(m.Config.Tags, m.Config.Severity, m.Config.ExcludeIDs, m.Config.ExcludeTags)
+	if err != nil {
+		return err
 	}
+	selectedServices := m.Templates.FilterServices(m.Templates.GetService(m.Config.ServiceID, services), filter)
 
 	// List services if requested
 	if m.Config.ListServices {
-		m.Templates.PrintServices(services, termWidth)
+		m.Templates.PrintServices(selectedServices, termWidth)
 		return nil
 	}
 
 	// Check that a target is specified
-	if m.Config.Target == "" {
-		return fmt.Errorf("no target specified, use -target flag to specify a target")
+	if m.Config.Target == "" && m.Config.TargetList == "" {
+		return fmt.Errorf("no target specified, use -target or -target-list flag to specify a target")
 	}
 
-	// Get selected services
-	selectedServices := m.Templates.GetService(m.Config.ServiceID, services)
 	if len(selectedServices) == 0 {
-		fmt.Fprintf(os.Stderr, "[-] Error: Service ID %q does not match any integrated service!\n\nAvailable Services:\n",
-			m.Config.ServiceID)
+		if filter.Empty() {
+			fmt.Fprintf(os.Stderr, "[-] Error: Service ID %q does not match any integrated service!\n\nAvailable Services:\n",
+				m.Config.ServiceID)
+		} else {
+			fmt.Fprintf(os.Stderr, "[-] Error: Service ID %q and the service filters do not match any integrated service!\n\nAvailable Services:\n",
+				m.Config.ServiceID)
+		}
 		m.Templates.PrintServices(services, termWidth)
 		return fmt.Errorf("no services selected")
 	}
 
+	// Skip invalid templates instead of failing (or silently missing) on every request
+	selectedServices = slices.DeleteFunc(selectedServices, func(service types.Service) bool {
+		problems := templates.Check(&service)
+		if len(problems) > 0 {
+			fmt.Fprintf(os.Stderr, "[-] Warning: Skipping invalid template %d (%s): %v (run \"misconfig-mapper templates validate\" for details)\n",
+				service.ID, service.Metadata.ServiceName, problems[0])
+		}
+		return len(problems) > 0
+	})
+	if len(selectedServices) == 0 {
+		return fmt.Errorf("no valid services selected")
+	}
+
 	if m.Config.Verbosity >= types.Verbose {
 		fmt.Printf("[+] %v Services selected!\n", len(selectedServices))
 	}
 
+	// Expand the template variables into a request variant for each combination of values
+	selectedServices, unused := templates.ExpandVariables(selectedServices, m.Config.Variables)
+	for _, name := range unused {
+		fmt.Fprintf(os.Stderr, "[-] Warning: None of the selected services declares the template variable %q... Ignoring -var %s!\n", name, name)
+	}
+
 	// Create HTTP client
 	httpClient := client.NewHTTPClient(
 		m.Config.Timeout,
@@ -101,6 +163,37 @@ func (m *MisconfigMapper) Run() error {
 		m.Config.Verbosity,
 		m.Config.SkipSSL,
 	)
+	httpClient.SetRetryPolicy(client.RetryPolicy{
+		MaxAttempts:  m.Config.Retries + 1,
+		Backoff:      time.Duration(m.Config.RetryBackoff) * time.Millisecond,
+		MaxBackoff:   time.Duration(m.Config.RetryMaxBackoff) * time.Millisecond,
+		ErrorClasses: m.Config.RetryOn,
+		StatusCodes:  m.Config.RetryStatus,
+	})
+	httpClient.SetTransportOptions(client.TransportOptions{
+		DisableKeepAlives:   m.Config.NoKeepAlive,
+		MaxIdleConns:        m.Config.MaxIdleConns,
+		MaxIdleConnsPerHost: m.Config.MaxIdlePerHost,
+		IdleConnTimeout:     time.Duration(m.Config.IdleTimeout) * time.Millisecond,
+		HTTP2:               m.Config.HTTP2,
+	})
+	httpClient.SetHostLimiter(ratelimit.NewHostLimiter(
+		m.Config.HostRateLimit,
+		time.Duration(m.Config.MaxBackoff)*time.Millisecond,
+	))
+
+	// Route all requests through the proxies if requested
+	if len(m.Config.Proxies) > 0 {
+		pool, err := client.NewProxyPool(m.Config.Proxies, m.Config.ProxyRotation)
+		if err != nil {
+			return err
+		}
+		httpClient.SetProxyPool(pool)
+
+		if m.Config.Verbosity >= types.Verbose {
+			fmt.Printf("[+] Sending requests through %d proxy(s) (%s): %s\n", len(pool.Proxies), pool.Rotation, pool)
+		}
+	}
 
 	// Create scanner
 	scn := scanner.NewScanner(
@@ -113,9 +206,77 @@ func (m *MisconfigMapper) Run() error {
 		termWidth,
 		m.Config.Verbosity,
 		m.Config.Delay,
+		m.Config.Concurrency,
 	)
-	scn.SetSelectedServices(selectedServices)
+	if err := scn.SetSelectedServices(selectedServices); err != nil {
+		return err
+	}
+	scn.SetTargetList(m.Config.TargetList)
+	scn.SetSkipDetection(m.Config.SkipDetection)
+	scn.SetReportDetected(m.Config.ReportDetected)
+
+	// Set up the permutation engine
+	if m.Config.EnablePerms {
+		engine, err := m.PermutationEngine()
+		if err != nil {
+			return fmt.Errorf("failed to load permutations: %w", err)
+		}
+		scn.SetPermutationEngine(engine)
+	}
+
+	// Pre-resolve hostnames if requested
+	if m.Config.DNSFilter {
+		scn.SetResolver(resolver.New(m.Config.Resolvers, dnsTimeout))
+	}
+
+	// Record progress in a checkpoint file if requested
+	if m.Config.ResumeFile != "" {
+		scan := fmt.Sprintf("target=%s target-list=%s as-domain=%v permutations=%v permutation-profile=%s permutation-patterns=%q wordlists=%v normalize=%v derive-keyword=%v vars=%v skip-misconfiguration-checks=%v skip-detection=%v",
+			m.Config.Target, m.Config.TargetList, m.Config.AsDomain, m.Config.EnablePerms, m.Config.PermProfile, m.Config.PermPatterns, m.Config.PermWordlists,
+			m.Config.Normalize, m.Config.DeriveKeyword, m.Config.Variables, m.Config.SkipChecks, m.Config.SkipDetection)
+
+		cp, err := checkpoint.Open(m.Config.ResumeFile, scan)
+		if err != nil {
+			return fmt.Errorf("failed to open checkpoint: %w", err)
+		}
+		defer func() {
+			if err := cp.Close(); err != nil {
+				fmt.Fprintf(os.Stderr, "[-] Error: %v\n", err)
+			}
+		}()
+
+		scn.SetCheckpoint(cp)
+	}
 
 	// Run the scan
-	return scn.ScanTargets()
+	return scn.ScanTargets(ctx)
+}
+
+// PermutationEngine builds the permutation engine from the selected profile, wordlists and patterns
+func (m *MisconfigMapper) PermutationEngine() (*permutation.Engine, error) {
+	profile, err := permutation.LoadProfile(m.Config.PermProfile)
+	if err != nil {
+		return nil, err
+	}
+
+	for name, path := range m.Config.PermWordlists {
+		if err := profile.LoadWordlist(name, path); err != nil {
+			return nil, fmt.Errorf("failed to load wordlist %q: %w", name, err)
+		}
+	}
+
+	if len(m.Config.PermPatterns) > 0 {
+		profile.Patterns = m.Config.PermPatterns
+	}
+
+	engine, err := permutation.NewEngine(profile)
+	if err != nil {
+		return nil, err
+	}
+
+	if m.Config.Normalize {
+		engine.SetNormalizer(&permutation.Normalizer{DeriveFromDomain: m.Config.DeriveKeyword})
+	}
+
+	return engine, nil
 }
@@ -0,0 +1,112 @@
+package service
+
+import (
+	"context"
+	"crypto/ed25519"
+	"fmt"
+
+	"github.com/intigriti/misconfig-mapper/internal/config"
+	"github.com/intigriti/misconfig-mapper/internal/types"
+	"github.com/intigriti/misconfig-mapper/pkg/templates"
+	defaults "github.com/intigriti/misconfig-mapper/templates"
+)
+
+// RunTemplatesCommand runs a "templates" subcommand until it completes or the context is cancelled
+func RunTemplatesCommand(ctx context.Context, cmd *config.TemplatesCommand) error {
+	switch cmd.Action {
+	case config.TemplatesValidate:
+		return validateTemplates(cmd)
+	case config.TemplatesTest:
+		return testTemplates(ctx, cmd)
+	case config.TemplatesRollback:
+		return templates.NewManager(cmd.TemplatesPaths, cmd.Verbosity).RollbackTemplates()
+	case config.TemplatesManifest:
+		return generateManifest(cmd)
+	case config.TemplatesExport:
+		return exportTemplates(cmd)
+	default:
+		return fmt.Errorf("unknown templates command %q", cmd.Action)
+	}
+}
+
+// validateTemplates validates template files against the template schema and reports every problem with its position
+func validateTemplates(cmd *config.TemplatesCommand) error {
+	validator := templates.NewValidator()
+
+	for _, path := range cmd.Paths {
+		if err := validator.ValidatePath(path); err != nil {
+			return fmt.Errorf("failed to validate templates: %w", err)
+		}
+	}
+
+	for _, issue := range validator.Issues {
+		fmt.Printf("[-] %s\n", issue)
+	}
+
+	if len(validator.Issues) > 0 {
+		return fmt.Errorf("found %d problem(s) in %d template(s)", len(validator.Issues), validator.Services)
+	}
+
+	if cmd.Verbosity >= types.Normal {
+		fmt.Printf("[+] Info: %d template(s) in %d file(s) are valid!\n", validator.Services, validator.Files)
+	}
+
+	return nil
+}
+
+// generateManifest writes a (signed) manifest of the templates folder, to publish it as a template update source
+func generateManifest(cmd *config.TemplatesCommand) error {
+	dir := cmd.Paths[0]
+
+	var key ed25519.PrivateKey
+	if cmd.SigningKey != "" {
+		var err error
+		if key, err = templates.LoadPrivateKey(cmd.SigningKey); err != nil {
+			return err
+		}
+	}
+
+	// Continue from the version of the existing manifest
+	version := cmd.Version
+	if version == 0 {
+		existing, err := templates.NewManager([]string{dir}, cmd.Verbosity).InstalledManifest()
+		if err != nil {
+			return fmt.Errorf("failed to read existing manifest: %w", err)
+		}
+		version = 1
+		if existing != nil {
+			version = existing.Version + 1
+		}
+	}
+
+	manifest, err := templates.GenerateManifest(dir, version, key)
+	if err != nil {
+		return fmt.Errorf("failed to generate manifest: %w", err)
+	}
+
+	if cmd.Verbosity >= types.Normal {
+		signed := "unsigned"
+		if key != nil {
+			signed = "signed"
+		}
+		fmt.Printf("[+] Info: Wrote %s manifest version %d with %d file(s) to %s\n", signed, manifest.Version, len(manifest.Files), dir)
+	}
+
+	return nil
+}
+
+// exportTemplates writes the templates embedded in the binary to disk, to customize them or use them with another tool
+func exportTemplates(cmd *config.TemplatesCommand) error {
+	dir := cmd.Paths[0]
+
+	written, err := templates.Export(defaults.Bundle, dir, cmd.Force)
+	if err != nil {
+		return err
+	}
+
+	if cmd.Verbosity >= types.Normal {
+		fmt.Printf("[+] Info: Exported %d embedded template file(s) to %s\n", written, dir)
+	}
+
+	return nil
+}
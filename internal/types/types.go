@@ -2,36 +2,145 @@ package types
 
 // Service represents a service configuration from the template file
 type Service struct {
-	ID      int64 `json:"id"`
-	Request struct {
-		Method  string              `json:"method"`
-		BaseURL string              `json:"baseURL"`
-		Path    []string            `json:"path"`
-		Headers []map[string]string `json:"headers"`
-		Body    any                 `json:"body"`
+	ID        int64               `json:"id"`
+	Variables map[string][]string `json:"variables,omitempty"` // Template variables and their values, each combination of values is a separate request
+	Request   struct {
+		Method        string              `json:"method"`
+		BaseURL       string              `json:"baseURL"`
+		Path          []string            `json:"path"`
+		DetectionPath string              `json:"detectionPath,omitempty"`
+		Headers       []map[string]string `json:"headers"`
+		Body          any                 `json:"body"`
+		BodyEncoding  string              `json:"bodyEncoding,omitempty"` // json, form, raw or base64 (default: derived from the Content-Type header and the body)
+		Steps         []Step              `json:"steps,omitempty"`
 	} `json:"request"`
 	Response struct {
-		StatusCode            interface{} `json:"statusCode"`
-		DetectionFingerprints []string    `json:"detectionFingerprints"`
-		Fingerprints          []string    `json:"fingerprints"`
-		ExclusionPatterns     []string    `json:"exclusionPatterns,omitempty"`
+		StatusCode            interface{}   `json:"statusCode"`
+		DetectionFingerprints []string      `json:"detectionFingerprints"`
+		Fingerprints          []string      `json:"fingerprints"`
+		ExclusionPatterns     []string      `json:"exclusionPatterns,omitempty"`
+		DetectionMatchers     *MatcherGroup `json:"detectionMatchers,omitempty"`
+		Matchers              *MatcherGroup `json:"matchers,omitempty"`
+		Extractors            []Extractor   `json:"extractors,omitempty"`
 	} `json:"response"`
-	Metadata struct {
+	PermutationRules *PermutationRules `json:"permutationRules,omitempty"`
+	Metadata         struct {
 		Service           string   `json:"service"`
 		ServiceName       string   `json:"serviceName"`
 		Description       string   `json:"description"`
+		Severity          string   `json:"severity,omitempty"` // info, low, medium, high or critical
+		Category          string   `json:"category,omitempty"`
+		Tags              []string `json:"tags,omitempty"`
 		ReproductionSteps []string `json:"reproductionSteps"`
 		References        []string `json:"references"`
 	} `json:"metadata"`
 }
 
+// Step is a request in a multi-step request chain.
+// Values captured by its extractors are available as {name} variables in the paths, headers and bodies of the next steps.
+type Step struct {
+	Name         string              `json:"name,omitempty"`
+	Method       string              `json:"method,omitempty"`       // Request method (default: the method of the service)
+	Path         string              `json:"path,omitempty"`         // Request path, e.g. "{PATH}?token={csrf}" (default: the path being checked)
+	Headers      []map[string]string `json:"headers,omitempty"`      // Added to the headers of the service
+	Body         any                 `json:"body,omitempty"`         // Request body
+	BodyEncoding string              `json:"bodyEncoding,omitempty"` // Encoding of the request body (default: derived from the Content-Type header and the body)
+	Matchers     *MatcherGroup       `json:"matchers,omitempty"`     // Stops the chain if the response does not match
+	Extractors   []Extractor         `json:"extractors,omitempty"`   // Captures variables for the next steps
+}
+
+// Body encodings
+const (
+	EncodingJSON   = "json"   // Objects and arrays are marshaled to JSON, strings are sent as is
+	EncodingForm   = "form"   // Objects are URL encoded as form fields, strings are sent as is
+	EncodingRaw    = "raw"    // Strings are sent as is
+	EncodingBase64 = "base64" // Base64 encoded strings are decoded, for binary bodies
+)
+
+// Matcher types
+const (
+	MatcherWord   = "word"   // Matches literal words in a part of the response
+	MatcherRegex  = "regex"  // Matches regular expressions in a part of the response
+	MatcherStatus = "status" // Matches the response status code
+	MatcherHeader = "header" // Matches the value (or presence) of a response header
+	MatcherJSON   = "json"   // Matches the value (or presence) of a JSON path in the response body
+)
+
+// Response parts a matcher can be evaluated against
+const (
+	PartHeader = "header" // All response headers
+	PartBody   = "body"   // The response body
+	PartAll    = "all"    // The response headers and body
+)
+
+// Conditions used to combine matchers or matcher values
+const (
+	ConditionAnd = "and"
+	ConditionOr  = "or"
+)
+
+// Matcher describes a single condition a response has to satisfy
+type Matcher struct {
+	Type            string   `json:"type"`                      // Matcher type (word, regex, status, header or json)
+	Part            string   `json:"part,omitempty"`            // Response part to match (header, body or all, default: all)
+	Words           []string `json:"words,omitempty"`           // Literal words to look for
+	Regex           []string `json:"regex,omitempty"`           // Regular expressions to look for
+	Status          []int    `json:"status,omitempty"`          // Status codes to match
+	Name            string   `json:"name,omitempty"`            // Header name for header matchers
+	Path            string   `json:"path,omitempty"`            // JSON path for json matchers, e.g. "data.__schema.types[0].name"
+	Condition       string   `json:"condition,omitempty"`       // Whether all (and) or any (or) of the values must match (default: or)
+	Negative        bool     `json:"negative,omitempty"`        // Invert the outcome of the matcher
+	CaseInsensitive bool     `json:"caseInsensitive,omitempty"` // Match words and regular expressions case-insensitively
+}
+
+// MatcherGroup combines several matchers
+type MatcherGroup struct {
+	Condition string    `json:"condition,omitempty"` // Whether all (and) or any (or) of the matchers must match (default: and)
+	Matchers  []Matcher `json:"matchers"`
+}
+
+// Extractor types
+const (
+	ExtractorRegex  = "regex"  // Extracts regular expression (group) matches from a part of the response
+	ExtractorJSON   = "json"   // Extracts the values at a JSON path in the response body
+	ExtractorHeader = "header" // Extracts the values of a response header
+)
+
+// Extractor captures proof of a finding from the response
+type Extractor struct {
+	Name   string   `json:"name"`             // Name of the evidence
+	Type   string   `json:"type"`             // Extractor type (regex, json or header)
+	Part   string   `json:"part,omitempty"`   // Response part for regex extractors (header, body or all, default: all)
+	Regex  []string `json:"regex,omitempty"`  // Regular expressions to extract
+	Group  int      `json:"group,omitempty"`  // Capture group of the regular expressions to extract (default: 0, the full match)
+	Path   string   `json:"path,omitempty"`   // JSON path for json extractors, e.g. "data.__schema.types[*].name"
+	Header string   `json:"header,omitempty"` // Header name for header extractors
+}
+
+// Evidence holds the values captured by an extractor
+type Evidence struct {
+	Name   string   `json:"name"`
+	Values []string `json:"values"`
+}
+
+// PermutationRules restricts the candidate names that are valid for a service
+type PermutationRules struct {
+	AllowedCharacters   string   `json:"allowedCharacters,omitempty"`   // Regex character class, e.g. "a-z0-9-"
+	MinLength           int      `json:"minLength,omitempty"`           // Minimum length of a candidate name
+	MaxLength           int      `json:"maxLength,omitempty"`           // Maximum length of a candidate name
+	ForbiddenConnectors []string `json:"forbiddenConnectors,omitempty"` // Connectors that cannot occur in a candidate name, e.g. "."
+}
+
 // Result represents a scan result
 type Result struct {
-	URL        string  `json:"url"`        // Result URL
-	Exists     bool    `json:"exists"`     // Used to report back in case the instance exists
-	Vulnerable bool    `json:"vulnerable"` // Used to report back in case the instance is vulnerable
-	ServiceId  string  `json:"serviceid"`  // Service ID
-	Service    Service `json:"service"`    // Service struct
+	URL        string     `json:"url"`                // Result URL
+	Target     string     `json:"target,omitempty"`   // Value of the {TARGET} placeholder
+	Exists     bool       `json:"exists"`             // Used to report back in case the instance exists
+	Vulnerable bool       `json:"vulnerable"`         // Used to report back in case the instance is vulnerable
+	ServiceId  string     `json:"serviceid"`          // Service ID
+	Attempts   int        `json:"attempts"`           // Number of requests it took to get a response (including request steps)
+	Evidence   []Evidence `json:"evidence,omitempty"` // Proof captured by the extractors of the service
+	Service    Service    `json:"service"`            // Service struct
 }
 
 // VerbosityLevel represents the level of output detail
@@ -0,0 +1,124 @@
+package client
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/intigriti/misconfig-mapper/internal/types"
+	"github.com/intigriti/misconfig-mapper/pkg/templates"
+)
+
+// EncodeBody replaces the {NAME} placeholders in a template request body and encodes it.
+// Unless an encoding is set, it is derived from the content type (JSON or form) or else from the body itself:
+// strings are sent as is, objects and arrays as JSON. It also returns the content type that is sent if the
+// request doesn't set one. A nil body is not sent at all.
+func EncodeBody(body any, encoding, contentType string, variables map[string]string) ([]byte, string, error) {
+	if body == nil {
+		return nil, "", nil
+	}
+
+	if encoding == "" {
+		encoding = BodyEncoding(body, contentType)
+	}
+
+	switch encoding {
+	case types.EncodingJSON:
+		if s, ok := body.(string); ok {
+			return []byte(templates.Interpolate(s, variables)), "application/json", nil
+		}
+
+		data, err := json.Marshal(templates.InterpolateBody(body, variables))
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to encode JSON body: %w", err)
+		}
+		return data, "application/json", nil
+
+	case types.EncodingForm:
+		if s, ok := body.(string); ok {
+			return []byte(templates.Interpolate(s, variables)), "application/x-www-form-urlencoded", nil
+		}
+
+		values, err := formValues(templates.InterpolateBody(body, variables))
+		if err != nil {
+			return nil, "", err
+		}
+		return []byte(values.Encode()), "application/x-www-form-urlencoded", nil
+
+	case types.EncodingRaw:
+		s, ok := body.(string)
+		if !ok {
+			return nil, "", fmt.Errorf("raw body must be a string")
+		}
+		return []byte(templates.Interpolate(s, variables)), "", nil
+
+	case types.EncodingBase64:
+		s, ok := body.(string)
+		if !ok {
+			return nil, "", fmt.Errorf("base64 body must be a string")
+		}
+
+		data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid base64 body: %w", err)
+		}
+		return data, "application/octet-stream", nil
+
+	default:
+		return nil, "", fmt.Errorf("unsupported body encoding %q (must be one of %s)", encoding, strings.Join(templates.BodyEncodings, ", "))
+	}
+}
+
+// BodyEncoding derives the encoding of a body without an explicit encoding from its content type and type
+func BodyEncoding(body any, contentType string) string {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+
+	switch {
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		return types.EncodingJSON
+	case mediaType == "application/x-www-form-urlencoded":
+		return types.EncodingForm
+	}
+
+	if _, ok := body.(string); ok {
+		return types.EncodingRaw
+	}
+	return types.EncodingJSON
+}
+
+// formValues converts a body object to form fields, arrays are sent as repeated fields
+func formValues(body any) (url.Values, error) {
+	fields, ok := body.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("form body must be an object or a string")
+	}
+
+	values := make(url.Values, len(fields))
+	for key, value := range fields {
+		items, ok := value.([]any)
+		if !ok {
+			items = []any{value}
+		}
+
+		for _, item := range items {
+			switch v := item.(type) {
+			case nil:
+				values.Add(key, "")
+			case string:
+				values.Add(key, v)
+			case bool:
+				values.Add(key, strconv.FormatBool(v))
+			case float64:
+				values.Add(key, strconv.FormatFloat(v, 'f', -1, 64))
+			default:
+				return nil, fmt.Errorf("form field %q must be a string, number, boolean or an array of them", key)
+			}
+		}
+	}
+
+	return values, nil
+}
@@ -0,0 +1,82 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/intigriti/misconfig-mapper/internal/types"
+)
+
+func TestEncodeBody(t *testing.T) {
+	variables := map[string]string{"TARGET": "acme", "HOST": "acme.example.com"}
+
+	tests := []struct {
+		name            string
+		body            any
+		encoding        string
+		contentType     string
+		want            string
+		wantContentType string
+		wantErr         bool
+	}{
+		{"no body", nil, "", "", "", "", false},
+		{"object as JSON", map[string]any{"query": "{TARGET}"}, "", "", `{"query":"acme"}`, "application/json", false},
+		{"object keys", map[string]any{"{TARGET}": []any{"{HOST}", 1.0, true}}, "", "", `{"acme":["acme.example.com",1,true]}`, "application/json", false},
+		{"string as raw", "name={TARGET}", "", "", "name=acme", "", false},
+		{"string with JSON content type", `{"name":"{TARGET}"}`, "", "application/json; charset=utf-8", `{"name":"acme"}`, "application/json", false},
+		{"object with JSON suffix content type", map[string]any{"a": "b"}, "", "application/vnd.api+json", `{"a":"b"}`, "application/json", false},
+		{"object with form content type", map[string]any{"user": "{TARGET}"}, "", "application/x-www-form-urlencoded", "user=acme", "application/x-www-form-urlencoded", false},
+		{"form", map[string]any{"user": "{TARGET}", "id": 1.5, "admin": false, "empty": nil}, types.EncodingForm, "", "admin=false&empty=&id=1.5&user=acme", "application/x-www-form-urlencoded", false},
+		{"form array", map[string]any{"scope": []any{"read", "write"}}, types.EncodingForm, "", "scope=read&scope=write", "application/x-www-form-urlencoded", false},
+		{"form string", "user={TARGET}", types.EncodingForm, "", "user=acme", "application/x-www-form-urlencoded", false},
+		{"form with nested object", map[string]any{"user": map[string]any{"name": "a"}}, types.EncodingForm, "", "", "", true},
+		{"form from array", []any{"a"}, types.EncodingForm, "", "", "", true},
+		{"raw", "{TARGET}\r\n", types.EncodingRaw, "text/plain", "acme\r\n", "", false},
+		{"raw object", map[string]any{"a": "b"}, types.EncodingRaw, "", "", "", true},
+		{"base64", "e1RBUkdFVH0AAQ==", types.EncodingBase64, "", "{TARGET}\x00\x01", "application/octet-stream", false},
+		{"invalid base64", "not base64!", types.EncodingBase64, "", "", "", true},
+		{"base64 object", map[string]any{"a": "b"}, types.EncodingBase64, "", "", "", true},
+		{"unsupported encoding", "a", "xml", "", "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, contentType, err := EncodeBody(tt.body, tt.encoding, tt.contentType, variables)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("EncodeBody() error = %v, want error %v", err, tt.wantErr)
+			}
+
+			if got := string(data); got != tt.want {
+				t.Errorf("EncodeBody() = %q, want %q", got, tt.want)
+			}
+			if contentType != tt.wantContentType {
+				t.Errorf("EncodeBody() content type = %q, want %q", contentType, tt.wantContentType)
+			}
+		})
+	}
+}
+
+func TestBodyEncoding(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        any
+		contentType string
+		want        string
+	}{
+		{"object", map[string]any{}, "", types.EncodingJSON},
+		{"array", []any{}, "", types.EncodingJSON},
+		{"string", "a=b", "", types.EncodingRaw},
+		{"JSON content type", "{}", "application/json", types.EncodingJSON},
+		{"JSON suffix content type", "{}", "application/problem+json", types.EncodingJSON},
+		{"form content type", map[string]any{}, "application/x-www-form-urlencoded; charset=utf-8", types.EncodingForm},
+		{"other content type", "<a/>", "application/xml", types.EncodingRaw},
+		{"invalid content type", map[string]any{}, "application/json;;", types.EncodingJSON},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := BodyEncoding(tt.body, tt.contentType); got != tt.want {
+				t.Errorf("BodyEncoding() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
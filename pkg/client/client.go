@@ -3,15 +3,19 @@ package client
 import (
 	"bytes"
 	"context"
-	"crypto/tls"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
-	"regexp"
+	"slices"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/intigriti/misconfig-mapper/internal/types"
+	"github.com/intigriti/misconfig-mapper/pkg/matchers"
+	"github.com/intigriti/misconfig-mapper/pkg/ratelimit"
 	"github.com/intigriti/misconfig-mapper/pkg/templates"
 )
 
@@ -22,6 +26,12 @@ type HTTPClient struct {
 	Headers    map[string]string
 	SkipChecks bool
 	Verbosity  types.VerbosityLevel
+	Limiter    *ratelimit.HostLimiter
+	Retry      RetryPolicy
+	Proxies    *ProxyPool
+
+	mu       sync.Mutex
+	matchers map[string]*matchers.Service // Compiled matchers by service ID and variant
 }
 
 // NewHTTPClient creates a new HTTP client
@@ -34,12 +44,8 @@ func NewHTTPClient(timeout, maxRedirects int, headers map[string]string, skipChe
 			}
 			return nil
 		},
-		Timeout: time.Duration(timeout) * time.Millisecond,
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{
-				InsecureSkipVerify: skipSSL,
-			},
-		},
+		Timeout:   time.Duration(timeout) * time.Millisecond,
+		Transport: newTransport(DefaultTransportOptions(), skipSSL),
 	}
 
 	return &HTTPClient{
@@ -48,144 +54,414 @@ func NewHTTPClient(timeout, maxRedirects int, headers map[string]string, skipChe
 		Headers:    headers,
 		SkipChecks: skipChecks,
 		Verbosity:  verbosity,
+		Retry:      DefaultRetryPolicy(),
 	}
 }
 
-// CheckResponse checks if a service is vulnerable
-func (c *HTTPClient) CheckResponse(result *types.Result, service *types.Service) {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(c.Timeout)*time.Millisecond)
-	defer cancel()
+// SetRetryPolicy sets the policy used to retry requests that failed with a transient error
+func (c *HTTPClient) SetRetryPolicy(policy RetryPolicy) {
+	if policy.MaxAttempts < 1 {
+		policy.MaxAttempts = 1
+	}
+	c.Retry = policy
+}
 
-	var requestBody io.Reader = nil
-	if service.Request.Body != nil {
-		requestBody = bytes.NewBuffer([]byte(fmt.Sprintf("%v", service.Request.Body)))
+// SetHostLimiter sets the per-host rate limiter used to throttle and back off requests
+func (c *HTTPClient) SetHostLimiter(limiter *ratelimit.HostLimiter) {
+	c.Limiter = limiter
+}
+
+// SetTransportOptions sets how connections are pooled and reused
+func (c *HTTPClient) SetTransportOptions(options TransportOptions) {
+	if transport, ok := c.Client.Transport.(*http.Transport); ok {
+		applyTransportOptions(transport, options)
+	}
+}
+
+// SetProxyPool sets the proxies that all requests are sent through
+func (c *HTTPClient) SetProxyPool(pool *ProxyPool) {
+	c.Proxies = pool
+	if transport, ok := c.Client.Transport.(*http.Transport); ok {
+		transport.Proxy = pool.Proxy
+	}
+}
+
+// request describes a single HTTP request of a service or one of its steps
+type request struct {
+	Method  string
+	Headers []map[string]string
+	Body    []byte // Encoded request body, nil if no body is sent
+}
+
+// CheckResponse checks if a service is vulnerable, the request is aborted once the context is cancelled.
+// Services with request steps are checked against the response of their last step.
+// Only detection is checked if misconfiguration checks are skipped.
+func (c *HTTPClient) CheckResponse(ctx context.Context, result *types.Result, service *types.Service) {
+	c.check(ctx, result, service, c.SkipChecks)
+}
+
+// CheckDetection checks if an instance of a service exists, without performing the request steps of the service
+func (c *HTTPClient) CheckDetection(ctx context.Context, result *types.Result, service *types.Service) {
+	c.check(ctx, result, service, true)
+}
+
+// check requests the URL of a result and matches the response against the (detection) matchers of the service
+func (c *HTTPClient) check(ctx context.Context, result *types.Result, service *types.Service, detectionOnly bool) {
+	compiled, err := c.compile(service)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[-] Error: Invalid expression supplied for service %q (error: %v)!\n",
+			service.Metadata.ServiceName, err)
+		return
+	}
+
+	variables, err := requestVariables(result.URL, result.Target)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[-] Error: Invalid URL %q (error: %v)!\n", result.URL, err)
+		return
 	}
 
-	req, err := http.NewRequestWithContext(ctx, fmt.Sprintf("%v", service.Request.Method), result.URL, requestBody)
+	var (
+		res      *http.Response
+		body     []byte
+		attempts int
+		ok       = true
+	)
+	if len(service.Request.Steps) > 0 && !detectionOnly {
+		res, body, attempts, ok, err = c.chain(ctx, result.URL, variables, service, compiled)
+	} else {
+		var req request
+		headers := interpolateHeaders(service.Request.Headers, variables)
+		req, err = c.newRequest(service.Request.Method, headers, service.Request.Body, service.Request.BodyEncoding, variables)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[-] Error: Invalid request body supplied for service %q (error: %v)!\n",
+				service.Metadata.ServiceName, err)
+			return
+		}
+		res, body, attempts, err = c.send(ctx, result.URL, req)
+	}
+	result.Attempts = attempts
 	if err != nil {
+		// Requests aborted by a cancelled scan are not worth reporting
+		if ctx.Err() != nil {
+			return
+		}
+
 		if c.Verbosity >= types.Verbose {
-			fmt.Fprintf(os.Stderr, "[-] Error: Failed to request %s (%v)\n", result.URL, err)
+			fmt.Fprintf(os.Stderr, "[-] Error: Failed to request %s after %d attempt(s) (%v)\n", result.URL, attempts, err)
 		} else if c.Verbosity >= types.Normal {
 			fmt.Fprintf(os.Stderr, "[-] Error: Failed to request %s\n", result.URL)
 		}
+		result.Exists = false
 		result.Vulnerable = false
 		return
 	}
 
-	// Add headers from service template
-	if len(service.Request.Headers) > 0 {
-		for _, header := range service.Request.Headers {
-			for key, value := range header {
-				req.Header.Set(key, value)
-			}
-		}
-	}
-
-	// Add custom headers (these take precedence)
-	for key, value := range c.Headers {
-		req.Header.Set(key, value)
+	// The request chain broke off before its last step
+	if !ok {
+		result.Exists = false
+		result.Vulnerable = false
+		return
 	}
 
-	req.Header.Set("Connection", "close")
-
-	res, err := c.Client.Do(req)
-	if err != nil {
+	// Check exclusion patterns first
+	// If any exclusion pattern matches, consider this a false positive
+	if compiled.Exclusion != nil && compiled.Exclusion.Match(body) {
 		if c.Verbosity >= types.Verbose {
-			fmt.Fprintf(os.Stderr, "[-] Error: Failed to read response for %s (%v)\n", result.URL, err)
-		} else if c.Verbosity >= types.Normal {
-			fmt.Fprintf(os.Stderr, "[-] Error: Failed to read response for %s\n", result.URL)
+			fmt.Printf("[-] Info: Excluded %s due to matching exclusion pattern\n", result.URL)
 		}
 		result.Exists = false
 		result.Vulnerable = false
 		return
 	}
-	if res == nil {
-		fmt.Fprint(os.Stderr, "[-] Error: HTTP Response is empty")
-		return
+
+	response := matchers.NewResponse(res.StatusCode, res.Header, body)
+
+	if compiled.Detection != nil {
+		result.Exists = compiled.Detection.Match(response)
 	}
-	defer res.Body.Close()
 
-	// Check if status code matches
-	var statusCodeMatched bool
-	if statusCodes, ok := service.Response.StatusCode.([]interface{}); ok {
-		// Multiple status codes
-		for _, c := range statusCodes {
-			if int(c.(float64)) == res.StatusCode {
-				statusCodeMatched = true
-				break
+	if !detectionOnly {
+		// Check for vulnerability, a vulnerable instance exists even if the response lacks the detection fingerprints
+		result.Vulnerable = compiled.Vulnerability.Match(response)
+		result.Exists = result.Exists || result.Vulnerable
+	}
+
+	// Capture the proof of a finding
+	if result.Exists || result.Vulnerable {
+		result.Evidence = matchers.Evidence(compiled.Extractors, response)
+	}
+}
+
+// chain performs the request steps of a service in order and returns the response of the last step.
+// It reports false if the response of a step does not match or a step fails to capture its variables.
+func (c *HTTPClient) chain(ctx context.Context, targetURL string, variables map[string]string, service *types.Service, compiled *matchers.Service) (*http.Response, []byte, int, bool, error) {
+	var (
+		res      *http.Response
+		body     []byte
+		attempts int
+	)
+	for i, step := range service.Request.Steps {
+		name := step.Name
+		if name == "" {
+			name = fmt.Sprintf("%d", i+1)
+		}
+
+		path := step.Path
+		if path == "" {
+			path = "{PATH}"
+		}
+
+		// Step paths are relative to the scheme and host of the target URL unless they're a full URL
+		stepURL := templates.Interpolate(path, variables)
+		if !strings.HasPrefix(stepURL, "http://") && !strings.HasPrefix(stepURL, "https://") {
+			stepURL = variables["BASE_URL"] + stepURL
+		}
+
+		method := step.Method
+		if method == "" {
+			method = service.Request.Method
+		}
+
+		headers := interpolateHeaders(append(slices.Clone(service.Request.Headers), step.Headers...), variables)
+
+		req, err := c.newRequest(method, headers, step.Body, step.BodyEncoding, variables)
+		if err != nil {
+			return nil, nil, attempts, false, fmt.Errorf("step %q: %w", name, err)
+		}
+
+		var n int
+		res, body, n, err = c.send(ctx, stepURL, req)
+		attempts += n
+		if err != nil {
+			return nil, nil, attempts, false, err
+		}
+
+		response := matchers.NewResponse(res.StatusCode, res.Header, body)
+
+		if compiled.Steps[i].Matchers != nil && !compiled.Steps[i].Matchers.Match(response) {
+			if c.Verbosity >= types.Verbose {
+				fmt.Printf("[-] Info: Step %q of %s did not match (%s)\n", name, targetURL, stepURL)
 			}
+			return res, body, attempts, false, nil
 		}
-	} else {
-		// Single status code
-		if res.StatusCode == int(service.Response.StatusCode.(float64)) {
-			statusCodeMatched = true
+
+		for _, extractor := range compiled.Steps[i].Extractors {
+			values := extractor.Extract(response)
+			if len(values) == 0 {
+				if c.Verbosity >= types.Verbose {
+					fmt.Printf("[-] Info: Step %q of %s did not capture %q (%s)\n", name, targetURL, extractor.Name, stepURL)
+				}
+				return res, body, attempts, false, nil
+			}
+			variables[extractor.Name] = values[0]
 		}
 	}
 
-	// Extract response headers
-	var responseHeaders string
-	for key, values := range res.Header {
-		for _, value := range values {
-			responseHeaders += fmt.Sprintf("%v: %v\n", key, value)
+	return res, body, attempts, true, nil
+}
+
+// requestVariables returns the variables that are available in request bodies and request steps
+func requestVariables(targetURL, target string) (map[string]string, error) {
+	u, err := url.Parse(targetURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]string{
+		"TARGET":   target,
+		"BASE_URL": fmt.Sprintf("%s://%s", u.Scheme, u.Host),
+		"HOST":     u.Host,
+		"PATH":     u.RequestURI(),
+	}, nil
+}
+
+// interpolateHeaders returns a copy of request headers with the {NAME} placeholders replaced in their values
+func interpolateHeaders(headers []map[string]string, variables map[string]string) []map[string]string {
+	result := make([]map[string]string, 0, len(headers))
+	for _, header := range headers {
+		h := make(map[string]string, len(header))
+		for key, value := range header {
+			h[key] = templates.Interpolate(value, variables)
 		}
+		result = append(result, h)
 	}
 
-	// Read response body
-	body, err := io.ReadAll(res.Body)
+	return result
+}
+
+// newRequest encodes the body of a request, the content type of the encoding is sent unless a header sets one
+func (c *HTTPClient) newRequest(method string, headers []map[string]string, body any, encoding string, variables map[string]string) (request, error) {
+	contentType := c.contentType(headers)
+
+	data, defaultType, err := EncodeBody(body, encoding, contentType, variables)
 	if err != nil {
-		if c.Verbosity >= types.Verbose {
-			fmt.Fprintf(os.Stderr, "[-] Error: Failed to read response body for %s (%v)\n", result.URL, err)
-		} else if c.Verbosity >= types.Normal {
-			fmt.Fprintf(os.Stderr, "[-] Error: Failed to read response body for %s\n", result.URL)
+		return request{}, err
+	}
+
+	if contentType == "" && defaultType != "" {
+		headers = append(slices.Clone(headers), map[string]string{"Content-Type": defaultType})
+	}
+
+	return request{Method: method, Headers: headers, Body: data}, nil
+}
+
+// contentType returns the Content-Type header a request is sent with, custom headers take precedence
+func (c *HTTPClient) contentType(headers []map[string]string) string {
+	var contentType string
+	for _, header := range append(slices.Clone(headers), c.Headers) {
+		for key, value := range header {
+			if strings.EqualFold(key, "Content-Type") {
+				contentType = value
+			}
 		}
-		return
 	}
 
-	// Check exclusion patterns first
-	if len(service.Response.ExclusionPatterns) > 0 {
-		exclusionExpr := templates.ParseRegex(service.Response.ExclusionPatterns)
-		exclusionRe, err := regexp.Compile(exclusionExpr)
+	return contentType
+}
+
+// compile returns the compiled matchers of a service, templates are only compiled once per scan
+func (c *HTTPClient) compile(service *types.Service) (*matchers.Service, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Variants of a service can have different fingerprints
+	key := fmt.Sprintf("%d %s", service.ID, templates.Variant(*service))
+	if compiled, ok := c.matchers[key]; ok {
+		return compiled, nil
+	}
+
+	compiled, err := matchers.CompileService(service)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.matchers == nil {
+		c.matchers = make(map[string]*matchers.Service)
+	}
+	c.matchers[key] = compiled
+
+	return compiled, nil
+}
+
+// send performs the request of a service and retries it according to the retry policy.
+// It returns the number of attempts it took, hosts that respond with 429 or 503 are backed off before retrying
+// for as long as their Retry-After header requests.
+func (c *HTTPClient) send(ctx context.Context, targetURL string, req request) (*http.Response, []byte, int, error) {
+	u, err := url.Parse(targetURL)
+	if err != nil {
+		return nil, nil, 1, err
+	}
+
+	for attempt := 1; ; attempt++ {
+		res, body, err := c.do(ctx, u, req)
+
+		// Give up on permanent errors, cancelled scans and once all attempts are used
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "[-] Error: Invalid exclusion pattern supplied for service %q (error: %v)!\n",
-				service.Metadata.ServiceName, err)
-			return
-		}
+			if ctx.Err() != nil || attempt >= c.Retry.MaxAttempts || !c.Retry.retryError(err) {
+				return nil, nil, attempt, err
+			}
 
-		// If any exclusion pattern matches, consider this a false positive
-		if exclusionRe.MatchString(string(body)) {
 			if c.Verbosity >= types.Verbose {
-				fmt.Printf("[-] Info: Excluded %s due to matching exclusion pattern\n", result.URL)
+				fmt.Fprintf(os.Stderr, "[-] Warning: Request to %s failed (%v), retrying (attempt %d/%d)...\n",
+					u, err, attempt+1, c.Retry.MaxAttempts)
 			}
-			result.Exists = false
-			result.Vulnerable = false
-			return
+			if err := c.Retry.wait(ctx, attempt, 0); err != nil {
+				return nil, nil, attempt, err
+			}
+			continue
+		}
+
+		// Slow down all further requests to a host that signals it is overloaded
+		overloaded := res.StatusCode == http.StatusTooManyRequests || res.StatusCode == http.StatusServiceUnavailable
+		retryAfter := ratelimit.ParseRetryAfter(res.Header.Get("Retry-After"))
+		if c.Limiter != nil {
+			if overloaded {
+				delay := c.Limiter.Backoff(u.Host, retryAfter)
+				if c.Verbosity >= types.Verbose {
+					fmt.Fprintf(os.Stderr, "[-] Warning: %s responded with status code %d, backing off for %v\n",
+						u.Host, res.StatusCode, delay)
+				}
+			} else {
+				c.Limiter.Success(u.Host)
+			}
+		}
+
+		if !c.Retry.retryStatus(res.StatusCode) || attempt >= c.Retry.MaxAttempts {
+			return res, body, attempt, nil
+		}
+
+		// The host limiter already delays the next attempt
+		if c.Limiter != nil && overloaded {
+			continue
+		}
+
+		if c.Verbosity >= types.Verbose {
+			fmt.Fprintf(os.Stderr, "[-] Warning: %s responded with status code %d, retrying (attempt %d/%d)...\n",
+				u, res.StatusCode, attempt+1, c.Retry.MaxAttempts)
+		}
+		if err := c.Retry.wait(ctx, attempt, retryAfter); err != nil {
+			return nil, nil, attempt, err
+		}
+	}
+}
+
+// do sends a single request and reads the full response body
+func (c *HTTPClient) do(parent context.Context, u *url.URL, r request) (*http.Response, []byte, error) {
+	// Apply per-host rate limiting if configured
+	if c.Limiter != nil {
+		if err := c.Limiter.Wait(parent, u.Host); err != nil {
+			return nil, nil, err
 		}
 	}
 
-	fullResponse := fmt.Sprintf("%v %v", responseHeaders, string(body))
+	ctx, cancel := context.WithTimeout(parent, time.Duration(c.Timeout)*time.Millisecond)
+	defer cancel()
 
-	if c.SkipChecks {
-		// Only check for detection
-		expr := templates.ParseRegex(service.Response.DetectionFingerprints)
-		re, err := regexp.Compile(expr)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "[-] Error: Invalid detection expression supplied for service %q (error: %v)!\n",
-				service.Metadata.ServiceName, err)
-			return
+	var requestBody io.Reader = nil
+	if r.Body != nil {
+		requestBody = bytes.NewReader(r.Body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, fmt.Sprintf("%v", r.Method), u.String(), requestBody)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Add headers from service template
+	if len(r.Headers) > 0 {
+		for _, header := range r.Headers {
+			for key, value := range header {
+				setHeader(req, key, value)
+			}
 		}
+	}
 
-		result.Exists = re.MatchString(fullResponse)
-		return
+	// Add custom headers (these take precedence)
+	for key, value := range c.Headers {
+		setHeader(req, key, value)
 	}
 
-	// Check for vulnerability
-	expr := templates.ParseRegex(service.Response.Fingerprints)
-	re, err := regexp.Compile(expr)
+	res, err := c.Client.Do(req)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "[-] Error: Invalid expression supplied for service %q (error: %v)!\n",
-			service.Metadata.ServiceName, err)
+		return nil, nil, err
+	}
+	defer res.Body.Close()
+
+	// Read response body
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	return res, body, nil
+}
+
+// setHeader sets a request header, the Host header replaces the host the request is sent with
+func setHeader(req *http.Request, key, value string) {
+	if strings.EqualFold(key, "Host") {
+		req.Host = value
 		return
 	}
 
-	result.Vulnerable = (re.MatchString(fullResponse) && statusCodeMatched)
+	req.Header.Set(key, value)
 }
@@ -0,0 +1,70 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/intigriti/misconfig-mapper/internal/types"
+)
+
+func TestRequestHeaders(t *testing.T) {
+	received := make(chan *http.Request, 4)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r
+	}))
+	defer server.Close()
+
+	headers := []map[string]string{{
+		"Host":       "{TARGET}.example.com",
+		"X-Target":   "{TARGET}",
+		"X-Base-URL": "{BASE_URL}{PATH}",
+	}}
+
+	tests := []struct {
+		name  string
+		steps []types.Step
+	}{
+		{"single request", nil},
+		{"request steps", []types.Step{{Headers: []map[string]string{{"X-Step": "{HOST}"}}}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var service types.Service
+			service.ID = 1
+			service.Request.Method = "GET"
+			service.Request.Headers = headers
+			service.Request.Steps = tt.steps
+			service.Response.Fingerprints = []string{"vulnerable"}
+
+			c := NewHTTPClient(5000, 0, nil, false, types.Silent, true)
+			defer c.Client.CloseIdleConnections()
+
+			result := types.Result{URL: server.URL + "/acme/login", Target: "acme"}
+			c.CheckResponse(context.Background(), &result, &service)
+
+			r := <-received
+			if r.Host != "acme.example.com" {
+				t.Errorf("Host = %q, want %q", r.Host, "acme.example.com")
+			}
+			if got := r.Header.Get("X-Target"); got != "acme" {
+				t.Errorf("X-Target = %q, want %q", got, "acme")
+			}
+			if got, want := r.Header.Get("X-Base-URL"), server.URL+"/acme/login"; got != want {
+				t.Errorf("X-Base-URL = %q, want %q", got, want)
+			}
+			if tt.steps != nil {
+				if got, want := r.Header.Get("X-Step"), server.Listener.Addr().String(); got != want {
+					t.Errorf("X-Step = %q, want %q", got, want)
+				}
+			}
+
+			// The template is not modified
+			if service.Request.Headers[0]["X-Target"] != "{TARGET}" {
+				t.Errorf("CheckResponse() modified the template headers: %v", service.Request.Headers)
+			}
+		})
+	}
+}
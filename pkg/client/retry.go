@@ -0,0 +1,112 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Error classes that can be retried
+const (
+	RetryTimeout = "timeout" // Request, dial or TLS handshake timeouts
+	RetryReset   = "reset"   // Connections reset or closed by the peer
+	RetryTLS     = "tls"     // Failed or interrupted TLS handshakes
+	RetryDNS     = "dns"     // Temporary DNS resolution failures
+)
+
+// ErrorClasses lists all error classes that can be retried
+var ErrorClasses = []string{RetryTimeout, RetryReset, RetryTLS, RetryDNS}
+
+// RetryPolicy configures how requests that fail with a transient error are retried
+type RetryPolicy struct {
+	MaxAttempts  int           // Total number of attempts per request (1 = no retries)
+	Backoff      time.Duration // Delay before the first retry, doubles after each attempt
+	MaxBackoff   time.Duration // Upper bound for the delay between attempts
+	ErrorClasses []string      // Error classes to retry
+	StatusCodes  []int         // Response status codes to retry
+}
+
+// DefaultRetryPolicy returns the retry policy used when none is configured.
+// Requests aren't retried by default, raising MaxAttempts retries the listed error classes and status codes.
+// Timeouts aren't listed, as a host that doesn't respond in time rarely does on the next attempt.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:  1,
+		Backoff:      500 * time.Millisecond,
+		MaxBackoff:   10 * time.Second,
+		ErrorClasses: []string{RetryReset, RetryTLS},
+		StatusCodes:  []int{429, 503},
+	}
+}
+
+// retryError reports whether a request that failed with err should be sent again
+func (p RetryPolicy) retryError(err error) bool {
+	class := ErrorClass(err)
+	return class != "" && slices.Contains(p.ErrorClasses, class)
+}
+
+// retryStatus reports whether a request that received the status code should be sent again
+func (p RetryPolicy) retryStatus(statusCode int) bool {
+	return slices.Contains(p.StatusCodes, statusCode)
+}
+
+// wait sleeps before the next attempt or returns early once the context is cancelled.
+// A delay requested by the host with a Retry-After header replaces the backoff.
+func (p RetryPolicy) wait(ctx context.Context, attempt int, retryAfter time.Duration) error {
+	delay := p.Backoff << (attempt - 1)
+	if retryAfter > 0 {
+		delay = retryAfter
+	}
+	if p.MaxBackoff > 0 && (delay > p.MaxBackoff || delay <= 0) {
+		delay = p.MaxBackoff
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// ErrorClass returns the class of a request error, or an empty string if it is not transient
+func ErrorClass(err error) string {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return ""
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		if dnsErr.IsTemporary || dnsErr.IsTimeout {
+			return RetryDNS
+		}
+		return ""
+	}
+
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return RetryTimeout
+	}
+
+	var recordErr tls.RecordHeaderError
+	var alertErr tls.AlertError
+	if errors.As(err, &recordErr) || errors.As(err, &alertErr) || strings.Contains(err.Error(), "TLS handshake") {
+		return RetryTLS
+	}
+
+	if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return RetryReset
+	}
+
+	return ""
+}
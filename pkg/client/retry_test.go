@@ -0,0 +1,127 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// timeoutError is a net.Error that timed out
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestErrorClass(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"nil", nil, ""},
+		{"cancelled", &url.Error{Op: "Get", URL: "https://acme.example.com", Err: context.Canceled}, ""},
+		{"deadline exceeded", &url.Error{Op: "Get", URL: "https://acme.example.com", Err: context.DeadlineExceeded}, RetryTimeout},
+		{"dial timeout", &net.OpError{Op: "dial", Net: "tcp", Err: timeoutError{}}, RetryTimeout},
+		{"temporary DNS failure", &net.DNSError{Err: "server misbehaving", Name: "acme.example.com", IsTemporary: true}, RetryDNS},
+		{"DNS timeout", &net.DNSError{Err: "i/o timeout", Name: "acme.example.com", IsTimeout: true}, RetryDNS},
+		{"non-existent host", &net.DNSError{Err: "no such host", Name: "acme.example.com", IsNotFound: true}, ""},
+		{"TLS alert", fmt.Errorf("remote error: %w", tls.AlertError(40)), RetryTLS},
+		{"TLS record header", tls.RecordHeaderError{Msg: "first record does not look like a TLS handshake"}, RetryTLS},
+		{"TLS handshake message", errors.New("net/http: TLS handshake timeout"), RetryTLS},
+		{"connection reset", &net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)}, RetryReset},
+		{"broken pipe", &net.OpError{Op: "write", Net: "tcp", Err: os.NewSyscallError("write", syscall.EPIPE)}, RetryReset},
+		{"EOF", &url.Error{Op: "Get", URL: "https://acme.example.com", Err: io.EOF}, RetryReset},
+		{"unexpected EOF", io.ErrUnexpectedEOF, RetryReset},
+		{"connection refused", &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}, ""},
+		{"other error", errors.New("unsupported protocol scheme"), ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ErrorClass(tt.err); got != tt.want {
+				t.Errorf("ErrorClass(%v) = %q, want %q", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryPolicy(t *testing.T) {
+	policy := DefaultRetryPolicy()
+	if policy.MaxAttempts != 1 {
+		t.Errorf("MaxAttempts = %d, want 1 (retries are opt-in)", policy.MaxAttempts)
+	}
+
+	errorTests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"reset", io.EOF, true},
+		{"tls", tls.AlertError(40), true},
+		{"timeout", context.DeadlineExceeded, false},
+		{"dns", &net.DNSError{IsTemporary: true}, false},
+		{"not transient", errors.New("unsupported protocol scheme"), false},
+	}
+
+	for _, tt := range errorTests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := policy.retryError(tt.err); got != tt.want {
+				t.Errorf("retryError(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+
+	for statusCode, want := range map[int]bool{200: false, 404: false, 429: true, 500: false, 503: true} {
+		if got := policy.retryStatus(statusCode); got != want {
+			t.Errorf("retryStatus(%d) = %v, want %v", statusCode, got, want)
+		}
+	}
+}
+
+func TestRetryWait(t *testing.T) {
+	policy := RetryPolicy{Backoff: 10 * time.Millisecond, MaxBackoff: 50 * time.Millisecond}
+
+	tests := []struct {
+		name       string
+		attempt    int
+		retryAfter time.Duration
+		min, max   time.Duration
+	}{
+		{"first attempt", 1, 0, 10 * time.Millisecond, 40 * time.Millisecond},
+		{"doubled", 2, 0, 20 * time.Millisecond, 50 * time.Millisecond},
+		{"capped", 5, 0, 50 * time.Millisecond, 200 * time.Millisecond},
+		{"retry after", 1, 30 * time.Millisecond, 30 * time.Millisecond, 60 * time.Millisecond},
+		{"retry after capped", 1, time.Minute, 50 * time.Millisecond, 200 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := time.Now()
+			if err := policy.wait(context.Background(), tt.attempt, tt.retryAfter); err != nil {
+				t.Fatalf("wait() error = %v", err)
+			}
+
+			if elapsed := time.Since(start); elapsed < tt.min || elapsed > tt.max {
+				t.Errorf("wait() took %v, want between %v and %v", elapsed, tt.min, tt.max)
+			}
+		})
+	}
+
+	t.Run("cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		if err := policy.wait(ctx, 1, time.Minute); !errors.Is(err, context.Canceled) {
+			t.Errorf("wait() error = %v, want %v", err, context.Canceled)
+		}
+	})
+}
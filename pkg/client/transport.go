@@ -0,0 +1,53 @@
+package client
+
+import (
+	"crypto/tls"
+	"net/http"
+	"time"
+)
+
+// tlsSessionCacheSize is the number of TLS sessions kept to resume handshakes with hosts
+const tlsSessionCacheSize = 1024
+
+// TransportOptions configures how the HTTP client pools and reuses connections
+type TransportOptions struct {
+	DisableKeepAlives   bool          // Close the connection after every request instead of reusing it
+	MaxIdleConns        int           // Idle connections kept open across all hosts (0 = unlimited)
+	MaxIdleConnsPerHost int           // Idle connections kept open per host
+	IdleConnTimeout     time.Duration // How long an idle connection is kept open (0 = no limit)
+	HTTP2               bool          // Negotiate HTTP/2 with hosts that support it
+}
+
+// DefaultTransportOptions returns the transport options used when none are configured
+func DefaultTransportOptions() TransportOptions {
+	return TransportOptions{
+		MaxIdleConns:        100,
+		MaxIdleConnsPerHost: 10,
+		IdleConnTimeout:     90 * time.Second,
+	}
+}
+
+// newTransport creates the transport of the HTTP client. TLS sessions are cached so that new connections to a host
+// resume the previous handshake, even if connections aren't reused.
+func newTransport(options TransportOptions, skipSSL bool) *http.Transport {
+	transport := &http.Transport{
+		TLSClientConfig: &tls.Config{
+			InsecureSkipVerify: skipSSL,
+			ClientSessionCache: tls.NewLRUClientSessionCache(tlsSessionCacheSize),
+		},
+	}
+	applyTransportOptions(transport, options)
+
+	return transport
+}
+
+// applyTransportOptions sets the connection pooling options of a transport
+func applyTransportOptions(transport *http.Transport, options TransportOptions) {
+	transport.DisableKeepAlives = options.DisableKeepAlives
+	transport.MaxIdleConns = options.MaxIdleConns
+	transport.MaxIdleConnsPerHost = options.MaxIdleConnsPerHost
+	transport.IdleConnTimeout = options.IdleConnTimeout
+
+	// A custom TLS configuration disables HTTP/2 unless it is requested explicitly
+	transport.ForceAttemptHTTP2 = options.HTTP2
+}
@@ -0,0 +1,124 @@
+package matchers
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/intigriti/misconfig-mapper/internal/types"
+)
+
+// maxEvidenceValues limits the number of values an extractor captures, e.g. the keys of a large bucket listing
+const maxEvidenceValues = 25
+
+// Extractor is a compiled extractor
+type Extractor struct {
+	types.Extractor
+	regexes []*regexp.Regexp
+}
+
+// CompileExtractors validates and compiles the extractors of a service
+func CompileExtractors(extractors []types.Extractor) ([]*Extractor, error) {
+	var compiled []*Extractor
+
+	for i, e := range extractors {
+		e.Type = strings.ToLower(e.Type)
+		e.Part = strings.ToLower(e.Part)
+
+		if e.Name == "" {
+			return nil, fmt.Errorf("extractor %d: missing name", i)
+		}
+
+		if e.Part == "" {
+			e.Part = types.PartAll
+		}
+		if e.Part != types.PartHeader && e.Part != types.PartBody && e.Part != types.PartAll {
+			return nil, fmt.Errorf("extractor %q: invalid part %q (must be %q, %q or %q)", e.Name, e.Part, types.PartHeader, types.PartBody, types.PartAll)
+		}
+
+		c := &Extractor{Extractor: e}
+
+		switch e.Type {
+		case types.ExtractorRegex:
+			if len(e.Regex) == 0 {
+				return nil, fmt.Errorf("extractor %q: regex extractor without regex", e.Name)
+			}
+
+			for _, expr := range e.Regex {
+				re, err := regexp.Compile(expr)
+				if err != nil {
+					return nil, fmt.Errorf("extractor %q: invalid regex %q: %w", e.Name, expr, err)
+				}
+				if e.Group < 0 || e.Group > re.NumSubexp() {
+					return nil, fmt.Errorf("extractor %q: regex %q has no capture group %d", e.Name, expr, e.Group)
+				}
+				c.regexes = append(c.regexes, re)
+			}
+		case types.ExtractorJSON:
+			if e.Path == "" {
+				return nil, fmt.Errorf("extractor %q: json extractor without path", e.Name)
+			}
+			if _, err := LookupJSON(nil, e.Path); err != nil {
+				return nil, fmt.Errorf("extractor %q: %w", e.Name, err)
+			}
+		case types.ExtractorHeader:
+			if e.Header == "" {
+				return nil, fmt.Errorf("extractor %q: header extractor without header name", e.Name)
+			}
+		default:
+			return nil, fmt.Errorf("extractor %q: invalid extractor type %q", e.Name, e.Type)
+		}
+
+		compiled = append(compiled, c)
+	}
+
+	return compiled, nil
+}
+
+// Extract returns the unique values the extractor captures from the response
+func (e *Extractor) Extract(r *Response) []string {
+	var values []string
+	add := func(value string) {
+		if value != "" && len(values) < maxEvidenceValues && !slices.Contains(values, value) {
+			values = append(values, value)
+		}
+	}
+
+	switch e.Type {
+	case types.ExtractorRegex:
+		part := r.Part(e.Part)
+		for _, re := range e.regexes {
+			for _, match := range re.FindAllStringSubmatch(part, -1) {
+				add(match[e.Group])
+			}
+		}
+	case types.ExtractorJSON:
+		if document := r.JSON(); document != nil {
+			found, _ := LookupJSON(document, e.Path)
+			for _, value := range found {
+				add(Stringify(value))
+			}
+		}
+	case types.ExtractorHeader:
+		for _, value := range r.Header[http.CanonicalHeaderKey(e.Header)] {
+			add(value)
+		}
+	}
+
+	return values
+}
+
+// Evidence runs all extractors against the response, extractors that capture nothing are left out
+func Evidence(extractors []*Extractor, r *Response) []types.Evidence {
+	var evidence []types.Evidence
+
+	for _, e := range extractors {
+		if values := e.Extract(r); len(values) > 0 {
+			evidence = append(evidence, types.Evidence{Name: e.Name, Values: values})
+		}
+	}
+
+	return evidence
+}
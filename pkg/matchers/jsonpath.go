@@ -0,0 +1,92 @@
+package matchers
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// LookupJSON returns all values at a JSON path such as "data.__schema.types[0].name".
+// Use [*] to select every element of an array, e.g. "items[*].key".
+func LookupJSON(document any, path string) ([]any, error) {
+	values := []any{document}
+
+	for _, segment := range strings.Split(strings.TrimPrefix(path, "$."), ".") {
+		if segment == "" || segment == "$" {
+			continue
+		}
+
+		// Split a segment such as "types[0][*]" into the key and its indexes
+		key, indexes, _ := strings.Cut(segment, "[")
+		if indexes != "" {
+			indexes = "[" + indexes
+		}
+
+		if key != "" {
+			var next []any
+			for _, value := range values {
+				if object, ok := value.(map[string]any); ok {
+					if child, ok := object[key]; ok {
+						next = append(next, child)
+					}
+				}
+			}
+			values = next
+		}
+
+		for indexes != "" {
+			end := strings.Index(indexes, "]")
+			if !strings.HasPrefix(indexes, "[") || end < 0 {
+				return nil, fmt.Errorf("invalid JSON path %q", path)
+			}
+			index := indexes[1:end]
+			indexes = indexes[end+1:]
+
+			i, err := strconv.Atoi(index)
+			if err != nil && index != "*" {
+				return nil, fmt.Errorf("invalid JSON path %q: %w", path, err)
+			}
+
+			var next []any
+			for _, value := range values {
+				array, ok := value.([]any)
+				if !ok {
+					continue
+				}
+
+				if index == "*" {
+					next = append(next, array...)
+					continue
+				}
+
+				if i < 0 && i+len(array) >= 0 {
+					next = append(next, array[i+len(array)])
+				} else if i >= 0 && i < len(array) {
+					next = append(next, array[i])
+				}
+			}
+			values = next
+		}
+	}
+
+	return values, nil
+}
+
+// Stringify returns the string representation of a JSON value
+func Stringify(value any) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case nil:
+		return "null"
+	case float64, bool:
+		return fmt.Sprintf("%v", v)
+	default:
+		d, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprintf("%v", v)
+		}
+		return string(d)
+	}
+}
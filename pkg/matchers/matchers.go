@@ -0,0 +1,378 @@
+package matchers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/intigriti/misconfig-mapper/internal/types"
+)
+
+// Response is an HTTP response that matchers are evaluated against
+type Response struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+
+	headers string
+	json    any
+	parsed  bool
+}
+
+// NewResponse creates a new response to match against
+func NewResponse(statusCode int, header http.Header, body []byte) *Response {
+	var headers strings.Builder
+	for key, values := range header {
+		for _, value := range values {
+			headers.WriteString(fmt.Sprintf("%v: %v\n", key, value))
+		}
+	}
+
+	return &Response{
+		StatusCode: statusCode,
+		Header:     header,
+		Body:       body,
+		headers:    headers.String(),
+	}
+}
+
+// Part returns a part of the response as a string
+func (r *Response) Part(part string) string {
+	switch part {
+	case types.PartHeader:
+		return r.headers
+	case types.PartBody:
+		return string(r.Body)
+	default:
+		return fmt.Sprintf("%v %v", r.headers, string(r.Body))
+	}
+}
+
+// JSON returns the decoded response body, or nil if the body is not valid JSON
+func (r *Response) JSON() any {
+	if !r.parsed {
+		r.parsed = true
+		if err := json.Unmarshal(r.Body, &r.json); err != nil {
+			r.json = nil
+		}
+	}
+
+	return r.json
+}
+
+// Group is a compiled matcher group
+type Group struct {
+	condition string
+	matchers  []*matcher
+}
+
+// matcher is a compiled matcher
+type matcher struct {
+	types.Matcher
+	words   []string
+	regexes []*regexp.Regexp
+}
+
+// Compile validates and compiles a matcher group
+func Compile(group types.MatcherGroup) (*Group, error) {
+	g := &Group{condition: strings.ToLower(group.Condition)}
+	if g.condition == "" {
+		g.condition = types.ConditionAnd
+	}
+	if g.condition != types.ConditionAnd && g.condition != types.ConditionOr {
+		return nil, fmt.Errorf("invalid matchers condition %q (must be %q or %q)", group.Condition, types.ConditionAnd, types.ConditionOr)
+	}
+
+	if len(group.Matchers) == 0 {
+		return nil, fmt.Errorf("no matchers defined")
+	}
+
+	for i, m := range group.Matchers {
+		compiled, err := compileMatcher(m)
+		if err != nil {
+			return nil, fmt.Errorf("matcher %d: %w", i, err)
+		}
+		g.matchers = append(g.matchers, compiled)
+	}
+
+	return g, nil
+}
+
+// compileMatcher validates and compiles a single matcher
+func compileMatcher(m types.Matcher) (*matcher, error) {
+	m.Type = strings.ToLower(m.Type)
+	m.Part = strings.ToLower(m.Part)
+	m.Condition = strings.ToLower(m.Condition)
+
+	if m.Part == "" {
+		m.Part = types.PartAll
+	}
+	if m.Part != types.PartHeader && m.Part != types.PartBody && m.Part != types.PartAll {
+		return nil, fmt.Errorf("invalid part %q (must be %q, %q or %q)", m.Part, types.PartHeader, types.PartBody, types.PartAll)
+	}
+
+	if m.Condition == "" {
+		m.Condition = types.ConditionOr
+	}
+	if m.Condition != types.ConditionAnd && m.Condition != types.ConditionOr {
+		return nil, fmt.Errorf("invalid condition %q (must be %q or %q)", m.Condition, types.ConditionAnd, types.ConditionOr)
+	}
+
+	c := &matcher{Matcher: m}
+
+	switch m.Type {
+	case types.MatcherWord:
+		if len(m.Words) == 0 {
+			return nil, fmt.Errorf("word matcher without words")
+		}
+	case types.MatcherRegex:
+		if len(m.Regex) == 0 {
+			return nil, fmt.Errorf("regex matcher without regex")
+		}
+	case types.MatcherStatus:
+		if len(m.Status) == 0 {
+			return nil, fmt.Errorf("status matcher without status codes")
+		}
+	case types.MatcherHeader:
+		if m.Name == "" {
+			return nil, fmt.Errorf("header matcher without header name")
+		}
+	case types.MatcherJSON:
+		if m.Path == "" {
+			return nil, fmt.Errorf("json matcher without path")
+		}
+		if _, err := LookupJSON(nil, m.Path); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("invalid matcher type %q", m.Type)
+	}
+
+	for _, word := range m.Words {
+		if m.CaseInsensitive {
+			word = strings.ToLower(word)
+		}
+		c.words = append(c.words, word)
+	}
+
+	for _, expr := range m.Regex {
+		if m.CaseInsensitive {
+			expr = "(?i)" + expr
+		}
+
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex %q: %w", expr, err)
+		}
+		c.regexes = append(c.regexes, re)
+	}
+
+	return c, nil
+}
+
+// Match reports whether the response satisfies the matcher group
+func (g *Group) Match(r *Response) bool {
+	for _, m := range g.matchers {
+		matched := m.match(r)
+
+		if g.condition == types.ConditionOr && matched {
+			return true
+		}
+		if g.condition == types.ConditionAnd && !matched {
+			return false
+		}
+	}
+
+	return g.condition == types.ConditionAnd
+}
+
+// match evaluates a single matcher, taking negation into account
+func (m *matcher) match(r *Response) bool {
+	var matched bool
+
+	switch m.Type {
+	case types.MatcherStatus:
+		matched = slices.Contains(m.Status, r.StatusCode)
+	case types.MatcherHeader:
+		values, ok := r.Header[http.CanonicalHeaderKey(m.Name)]
+		matched = ok && m.matchValues(values)
+	case types.MatcherJSON:
+		var values []string
+		if document := r.JSON(); document != nil {
+			found, _ := LookupJSON(document, m.Path)
+			for _, value := range found {
+				values = append(values, Stringify(value))
+			}
+		}
+		matched = len(values) > 0 && m.matchValues(values)
+	default:
+		matched = m.matchValues([]string{r.Part(m.Part)})
+	}
+
+	return matched != m.Negative
+}
+
+// matchValues reports whether the words and regular expressions of the matcher occur in any of the values.
+// A matcher without words or regular expressions only checks for the presence of a value.
+func (m *matcher) matchValues(values []string) bool {
+	if len(m.words) == 0 && len(m.regexes) == 0 {
+		return true
+	}
+
+	haystack := strings.Join(values, "\n")
+	if m.CaseInsensitive {
+		haystack = strings.ToLower(haystack)
+	}
+
+	var checks []bool
+	for _, word := range m.words {
+		checks = append(checks, strings.Contains(haystack, word))
+	}
+	for _, re := range m.regexes {
+		checks = append(checks, re.MatchString(haystack))
+	}
+
+	if m.Condition == types.ConditionAnd {
+		return !slices.Contains(checks, false)
+	}
+	return slices.Contains(checks, true)
+}
+
+// ParseRegex transforms an array of patterns into a regex string
+func ParseRegex(v []string) string {
+	x := strings.Join(v, "|")             // Split array entries with regex alternation
+	x = strings.Replace(x, ".", `\.`, -1) // Escape dot characters
+	return x
+}
+
+// ParseStatusCodes parses the statusCode field of a template, which is a single status code or an array of them
+func ParseStatusCodes(statusCode any) ([]int, error) {
+	toInt := func(v any) (int, error) {
+		f, ok := v.(float64)
+		if !ok || f != float64(int(f)) {
+			return 0, fmt.Errorf("invalid status code %#v (must be an integer)", v)
+		}
+		return int(f), nil
+	}
+
+	switch v := statusCode.(type) {
+	case float64:
+		code, err := toInt(v)
+		if err != nil {
+			return nil, err
+		}
+		return []int{code}, nil
+	case []any:
+		var codes []int
+		for _, c := range v {
+			code, err := toInt(c)
+			if err != nil {
+				return nil, err
+			}
+			codes = append(codes, code)
+		}
+		return codes, nil
+	case nil:
+		return nil, fmt.Errorf("missing status code")
+	default:
+		return nil, fmt.Errorf("invalid status code %#v (must be an integer or an array of integers)", v)
+	}
+}
+
+// Legacy translates the fingerprints (and status codes) of a template without matchers into a matcher group.
+// The fingerprints are joined into a single regular expression that is matched against the headers and body.
+func Legacy(fingerprints []string, statusCode any) (*Group, error) {
+	group := types.MatcherGroup{
+		Condition: types.ConditionAnd,
+		Matchers: []types.Matcher{
+			{Type: types.MatcherRegex, Part: types.PartAll, Regex: []string{ParseRegex(fingerprints)}},
+		},
+	}
+
+	if statusCode != nil {
+		codes, err := ParseStatusCodes(statusCode)
+		if err != nil {
+			return nil, err
+		}
+		group.Matchers = append(group.Matchers, types.Matcher{Type: types.MatcherStatus, Status: codes})
+	}
+
+	return Compile(group)
+}
+
+// Service holds the compiled matchers of a service
+type Service struct {
+	Detection     *Group         // Confirms an instance of the service exists, nil if no detection fingerprints are defined
+	Vulnerability *Group         // Confirms the instance is misconfigured
+	Exclusion     *regexp.Regexp // Excludes false positives, nil if no exclusion patterns are defined
+	Extractors    []*Extractor   // Capture proof of a finding
+	Steps         []*Step        // Compiled request steps, in order
+}
+
+// Step holds the compiled matchers and extractors of a request step
+type Step struct {
+	Matchers   *Group // Nil if the step has no matchers
+	Extractors []*Extractor
+}
+
+// CompileService compiles the matchers of a service, falling back to its (legacy) fingerprints if none are defined
+func CompileService(service *types.Service) (*Service, error) {
+	var (
+		s   Service
+		err error
+	)
+
+	if service.Response.DetectionMatchers != nil {
+		s.Detection, err = Compile(*service.Response.DetectionMatchers)
+	} else if len(service.Response.DetectionFingerprints) > 0 {
+		// Detection fingerprints are matched regardless of the status code
+		s.Detection, err = Legacy(service.Response.DetectionFingerprints, nil)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid detection matchers: %w", err)
+	}
+
+	if service.Response.Matchers != nil {
+		s.Vulnerability, err = Compile(*service.Response.Matchers)
+	} else {
+		s.Vulnerability, err = Legacy(service.Response.Fingerprints, service.Response.StatusCode)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid matchers: %w", err)
+	}
+
+	if len(service.Response.ExclusionPatterns) > 0 {
+		s.Exclusion, err = regexp.Compile(ParseRegex(service.Response.ExclusionPatterns))
+		if err != nil {
+			return nil, fmt.Errorf("invalid exclusion pattern: %w", err)
+		}
+	}
+
+	s.Extractors, err = CompileExtractors(service.Response.Extractors)
+	if err != nil {
+		return nil, fmt.Errorf("invalid extractors: %w", err)
+	}
+
+	for i, step := range service.Request.Steps {
+		var compiled Step
+
+		if step.Matchers != nil {
+			compiled.Matchers, err = Compile(*step.Matchers)
+			if err != nil {
+				return nil, fmt.Errorf("invalid matchers of step %d: %w", i+1, err)
+			}
+		}
+
+		compiled.Extractors, err = CompileExtractors(step.Extractors)
+		if err != nil {
+			return nil, fmt.Errorf("invalid extractors of step %d: %w", i+1, err)
+		}
+
+		s.Steps = append(s.Steps, &compiled)
+	}
+
+	return &s, nil
+}
@@ -0,0 +1,279 @@
+package matchers
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/intigriti/misconfig-mapper/internal/types"
+)
+
+// testResponse is matched by the matcher tests
+func testResponse() *Response {
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+	header.Set("X-Request-Id", "abc123")
+	header.Add("Set-Cookie", "session=1")
+	header.Add("Set-Cookie", "theme=dark")
+
+	body := `{"data":{"__schema":{"types":[{"name":"Query"},{"name":"User"}]}},"public":true,"count":2}`
+
+	return NewResponse(200, header, []byte(body))
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		name  string
+		group types.MatcherGroup
+		want  bool
+	}{
+		{
+			name:  "word in body",
+			group: types.MatcherGroup{Matchers: []types.Matcher{{Type: "word", Part: "body", Words: []string{"__schema"}}}},
+			want:  true,
+		},
+		{
+			name:  "word in headers only",
+			group: types.MatcherGroup{Matchers: []types.Matcher{{Type: "word", Part: "body", Words: []string{"abc123"}}}},
+			want:  false,
+		},
+		{
+			name:  "word in all parts",
+			group: types.MatcherGroup{Matchers: []types.Matcher{{Type: "word", Words: []string{"X-Request-Id: abc123", "__schema"}, Condition: "and"}}},
+			want:  true,
+		},
+		{
+			name:  "any word",
+			group: types.MatcherGroup{Matchers: []types.Matcher{{Type: "word", Words: []string{"missing", "Query"}}}},
+			want:  true,
+		},
+		{
+			name:  "all words",
+			group: types.MatcherGroup{Matchers: []types.Matcher{{Type: "word", Words: []string{"missing", "Query"}, Condition: "AND"}}},
+			want:  false,
+		},
+		{
+			name:  "case-sensitive word",
+			group: types.MatcherGroup{Matchers: []types.Matcher{{Type: "word", Words: []string{"QUERY"}}}},
+			want:  false,
+		},
+		{
+			name:  "case-insensitive word",
+			group: types.MatcherGroup{Matchers: []types.Matcher{{Type: "word", Words: []string{"QUERY"}, CaseInsensitive: true}}},
+			want:  true,
+		},
+		{
+			name:  "regex",
+			group: types.MatcherGroup{Matchers: []types.Matcher{{Type: "regex", Part: "body", Regex: []string{`"count":\d+`}}}},
+			want:  true,
+		},
+		{
+			name:  "case-insensitive regex",
+			group: types.MatcherGroup{Matchers: []types.Matcher{{Type: "regex", Part: "header", Regex: []string{`content-type: application/json`}, CaseInsensitive: true}}},
+			want:  true,
+		},
+		{
+			name:  "status",
+			group: types.MatcherGroup{Matchers: []types.Matcher{{Type: "status", Status: []int{401, 200}}}},
+			want:  true,
+		},
+		{
+			name:  "other status",
+			group: types.MatcherGroup{Matchers: []types.Matcher{{Type: "status", Status: []int{401}}}},
+			want:  false,
+		},
+		{
+			name:  "negative status",
+			group: types.MatcherGroup{Matchers: []types.Matcher{{Type: "status", Status: []int{404}, Negative: true}}},
+			want:  true,
+		},
+		{
+			name:  "header presence",
+			group: types.MatcherGroup{Matchers: []types.Matcher{{Type: "header", Name: "x-request-id"}}},
+			want:  true,
+		},
+		{
+			name:  "missing header",
+			group: types.MatcherGroup{Matchers: []types.Matcher{{Type: "header", Name: "X-Powered-By"}}},
+			want:  false,
+		},
+		{
+			name:  "header value of repeated header",
+			group: types.MatcherGroup{Matchers: []types.Matcher{{Type: "header", Name: "Set-Cookie", Words: []string{"theme=dark"}}}},
+			want:  true,
+		},
+		{
+			name:  "json path",
+			group: types.MatcherGroup{Matchers: []types.Matcher{{Type: "json", Path: "data.__schema.types[*].name", Words: []string{"User"}}}},
+			want:  true,
+		},
+		{
+			name:  "json value",
+			group: types.MatcherGroup{Matchers: []types.Matcher{{Type: "json", Path: "$.public", Regex: []string{"^true$"}}}},
+			want:  true,
+		},
+		{
+			name:  "missing json path",
+			group: types.MatcherGroup{Matchers: []types.Matcher{{Type: "json", Path: "errors[0].message"}}},
+			want:  false,
+		},
+		{
+			name: "and group",
+			group: types.MatcherGroup{Matchers: []types.Matcher{
+				{Type: "status", Status: []int{200}},
+				{Type: "word", Words: []string{"missing"}},
+			}},
+			want: false,
+		},
+		{
+			name: "or group",
+			group: types.MatcherGroup{Condition: "or", Matchers: []types.Matcher{
+				{Type: "status", Status: []int{500}},
+				{Type: "word", Words: []string{"Query"}},
+			}},
+			want: true,
+		},
+		{
+			name: "negative matcher in and group",
+			group: types.MatcherGroup{Matchers: []types.Matcher{
+				{Type: "status", Status: []int{200}},
+				{Type: "word", Words: []string{"__schema"}, Negative: true},
+			}},
+			want: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			group, err := Compile(tt.group)
+			if err != nil {
+				t.Fatalf("Compile() error = %v", err)
+			}
+
+			if got := group.Match(testResponse()); got != tt.want {
+				t.Errorf("Match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		group   types.MatcherGroup
+		wantErr string
+	}{
+		{"no matchers", types.MatcherGroup{}, "no matchers defined"},
+		{"invalid group condition", types.MatcherGroup{Condition: "xor", Matchers: []types.Matcher{{Type: "status", Status: []int{200}}}}, "invalid matchers condition"},
+		{"invalid type", types.MatcherGroup{Matchers: []types.Matcher{{Type: "size"}}}, "invalid matcher type"},
+		{"invalid part", types.MatcherGroup{Matchers: []types.Matcher{{Type: "word", Part: "cookie", Words: []string{"a"}}}}, "invalid part"},
+		{"invalid condition", types.MatcherGroup{Matchers: []types.Matcher{{Type: "word", Words: []string{"a"}, Condition: "xor"}}}, "invalid condition"},
+		{"word without words", types.MatcherGroup{Matchers: []types.Matcher{{Type: "word"}}}, "without words"},
+		{"regex without regex", types.MatcherGroup{Matchers: []types.Matcher{{Type: "regex"}}}, "without regex"},
+		{"invalid regex", types.MatcherGroup{Matchers: []types.Matcher{{Type: "regex", Regex: []string{"("}}}}, "invalid regex"},
+		{"status without codes", types.MatcherGroup{Matchers: []types.Matcher{{Type: "status"}}}, "without status codes"},
+		{"header without name", types.MatcherGroup{Matchers: []types.Matcher{{Type: "header"}}}, "without header name"},
+		{"json without path", types.MatcherGroup{Matchers: []types.Matcher{{Type: "json"}}}, "without path"},
+		{"invalid json path", types.MatcherGroup{Matchers: []types.Matcher{{Type: "json", Path: "items[x]"}}}, "invalid JSON path"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Compile(tt.group)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Compile() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLegacy(t *testing.T) {
+	tests := []struct {
+		name         string
+		fingerprints []string
+		statusCode   any
+		want         bool
+	}{
+		{"fingerprint", []string{"missing", `"name":"User"`}, nil, true},
+		{"dots are literal", []string{`"count":2.`}, nil, false},
+		{"fingerprint and status", []string{"Query"}, float64(200), true},
+		{"fingerprint and other status", []string{"Query"}, float64(401), false},
+		{"status list", []string{"Query"}, []any{float64(302), float64(200)}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			group, err := Legacy(tt.fingerprints, tt.statusCode)
+			if err != nil {
+				t.Fatalf("Legacy() error = %v", err)
+			}
+
+			if got := group.Match(testResponse()); got != tt.want {
+				t.Errorf("Match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseStatusCodes(t *testing.T) {
+	tests := []struct {
+		name       string
+		statusCode any
+		want       []int
+		wantErr    bool
+	}{
+		{"single", float64(200), []int{200}, false},
+		{"list", []any{float64(200), float64(302)}, []int{200, 302}, false},
+		{"missing", nil, nil, true},
+		{"fraction", float64(200.5), nil, true},
+		{"string", "200", nil, true},
+		{"string in list", []any{float64(200), "302"}, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseStatusCodes(tt.statusCode)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseStatusCodes() error = %v, want error %v", err, tt.wantErr)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("ParseStatusCodes() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLookupJSON(t *testing.T) {
+	document := testResponse().JSON()
+
+	tests := []struct {
+		path string
+		want []string
+	}{
+		{"data.__schema.types[0].name", []string{"Query"}},
+		{"$.data.__schema.types[-1].name", []string{"User"}},
+		{"data.__schema.types[*].name", []string{"Query", "User"}},
+		{"data.__schema.types[5].name", nil},
+		{"count", []string{"2"}},
+		{"data.__schema.types[0]", []string{`{"name":"Query"}`}},
+		{"missing.key", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			values, err := LookupJSON(document, tt.path)
+			if err != nil {
+				t.Fatalf("LookupJSON() error = %v", err)
+			}
+
+			var got []string
+			for _, value := range values {
+				got = append(got, Stringify(value))
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("LookupJSON() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
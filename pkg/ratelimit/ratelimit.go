@@ -0,0 +1,176 @@
+package ratelimit
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	initialBackoff = time.Second     // Delay applied to a host the first time it signals it is overloaded
+	idleTimeout    = 5 * time.Minute // Hosts without requests for this long are forgotten
+)
+
+// HostLimiter rate limits requests per host and backs off hosts that signal they are overloaded
+type HostLimiter struct {
+	RequestsPerSecond float64       // Requests allowed per host per second (0 = unlimited)
+	MaxBackoff        time.Duration // Upper bound for the delay applied to an overloaded host
+
+	mu          sync.Mutex
+	hosts       map[string]*host
+	idleTimeout time.Duration
+	lastPrune   time.Time
+}
+
+// host holds the rate limiting state of a single host
+type host struct {
+	limiter      *rate.Limiter
+	backoff      time.Duration
+	blockedUntil time.Time
+	lastUsed     time.Time
+	waiting      int // Requests waiting for the host
+}
+
+// NewHostLimiter creates a new per-host rate limiter
+func NewHostLimiter(requestsPerSecond float64, maxBackoff time.Duration) *HostLimiter {
+	return &HostLimiter{
+		RequestsPerSecond: requestsPerSecond,
+		MaxBackoff:        maxBackoff,
+		hosts:             make(map[string]*host),
+		idleTimeout:       idleTimeout,
+		lastPrune:         time.Now(),
+	}
+}
+
+// get returns the state of a host, creating it on first use
+func (l *HostLimiter) get(name string) *host {
+	name = strings.ToLower(name)
+	now := time.Now()
+
+	l.prune(now)
+
+	h, ok := l.hosts[name]
+	if !ok {
+		h = &host{}
+		if l.RequestsPerSecond > 0 {
+			h.limiter = rate.NewLimiter(rate.Limit(l.RequestsPerSecond), 1)
+		}
+		l.hosts[name] = h
+	}
+	h.lastUsed = now
+
+	return h
+}
+
+// prune forgets the hosts that weren't used for the idle timeout, so scans of many hosts don't keep the state of every host.
+// Hosts with waiting requests or a pending backoff are kept. The hosts are checked at most once per idle timeout.
+func (l *HostLimiter) prune(now time.Time) {
+	if now.Sub(l.lastPrune) < l.idleTimeout {
+		return
+	}
+	l.lastPrune = now
+
+	for name, h := range l.hosts {
+		if h.waiting == 0 && now.Sub(h.lastUsed) >= l.idleTimeout && now.After(h.blockedUntil) {
+			delete(l.hosts, name)
+		}
+	}
+}
+
+// Wait blocks until a request to the host is allowed or the context is cancelled
+func (l *HostLimiter) Wait(ctx context.Context, name string) error {
+	l.mu.Lock()
+	h := l.get(name)
+	h.waiting++
+	delay := time.Until(h.blockedUntil)
+	l.mu.Unlock()
+
+	defer func() {
+		l.mu.Lock()
+		h.waiting--
+		h.lastUsed = time.Now()
+		l.mu.Unlock()
+	}()
+
+	// Honor any backoff that is currently applied to the host
+	if delay > 0 {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	if h.limiter == nil {
+		return nil
+	}
+
+	return h.limiter.Wait(ctx)
+}
+
+// Backoff delays all further requests to the host and returns the applied delay.
+// The delay doubles every consecutive time, unless the host specified one with a Retry-After header.
+func (l *HostLimiter) Backoff(name string, retryAfter time.Duration) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	h := l.get(name)
+
+	if h.backoff == 0 {
+		h.backoff = initialBackoff
+	} else {
+		h.backoff *= 2
+	}
+
+	delay := h.backoff
+	if retryAfter > 0 {
+		delay = retryAfter
+	}
+	if l.MaxBackoff > 0 && delay > l.MaxBackoff {
+		delay = l.MaxBackoff
+	}
+	if h.backoff > delay {
+		h.backoff = delay
+	}
+
+	// Don't shorten a backoff that was applied by another request
+	if until := time.Now().Add(delay); until.After(h.blockedUntil) {
+		h.blockedUntil = until
+	}
+
+	return delay
+}
+
+// Success resets the backoff of a host after it responded normally
+func (l *HostLimiter) Success(name string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.get(name).backoff = 0
+}
+
+// ParseRetryAfter parses the value of a Retry-After header, which is either a number of seconds or an HTTP date
+func ParseRetryAfter(value string) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0)
+	}
+
+	return 0
+}
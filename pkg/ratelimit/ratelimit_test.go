@@ -0,0 +1,151 @@
+package ratelimit
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  time.Duration
+	}{
+		{"seconds", "120", 2 * time.Minute},
+		{"seconds with whitespace", " 5 ", 5 * time.Second},
+		{"empty", "", 0},
+		{"zero", "0", 0},
+		{"negative", "-5", 0},
+		{"fraction", "1.5", 0},
+		{"invalid", "soon", 0},
+		{"past date", "Wed, 21 Oct 2015 07:28:00 GMT", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseRetryAfter(tt.value); got != tt.want {
+				t.Errorf("ParseRetryAfter(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+
+	t.Run("future date", func(t *testing.T) {
+		value := time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)
+
+		// The date has a precision of one second
+		if got := ParseRetryAfter(value); got <= 58*time.Second || got > time.Minute {
+			t.Errorf("ParseRetryAfter(%q) = %v, want about 1m", value, got)
+		}
+	})
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		name        string
+		maxBackoff  time.Duration
+		retryAfters []time.Duration
+		want        []time.Duration
+	}{
+		{
+			name:        "doubles",
+			retryAfters: []time.Duration{0, 0, 0},
+			want:        []time.Duration{time.Second, 2 * time.Second, 4 * time.Second},
+		},
+		{
+			name:        "capped",
+			maxBackoff:  3 * time.Second,
+			retryAfters: []time.Duration{0, 0, 0},
+			want:        []time.Duration{time.Second, 2 * time.Second, 3 * time.Second},
+		},
+		{
+			name:        "retry after",
+			retryAfters: []time.Duration{10 * time.Second, 0},
+			want:        []time.Duration{10 * time.Second, 2 * time.Second},
+		},
+		{
+			name:        "retry after capped",
+			maxBackoff:  5 * time.Second,
+			retryAfters: []time.Duration{time.Minute},
+			want:        []time.Duration{5 * time.Second},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewHostLimiter(0, tt.maxBackoff)
+
+			for i, retryAfter := range tt.retryAfters {
+				if got := l.Backoff("acme.example.com", retryAfter); got != tt.want[i] {
+					t.Errorf("Backoff() #%d = %v, want %v", i+1, got, tt.want[i])
+				}
+			}
+		})
+	}
+
+	t.Run("reset after success", func(t *testing.T) {
+		l := NewHostLimiter(0, 0)
+		l.Backoff("acme.example.com", 0)
+		l.Backoff("ACME.example.com", 0)
+		l.Success("acme.example.com")
+
+		if got := l.Backoff("acme.example.com", 0); got != time.Second {
+			t.Errorf("Backoff() after Success() = %v, want 1s", got)
+		}
+		if got := l.Backoff("other.example.com", 0); got != time.Second {
+			t.Errorf("Backoff() of another host = %v, want 1s", got)
+		}
+	})
+}
+
+func TestPrune(t *testing.T) {
+	l := NewHostLimiter(0.001, 0)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	for _, name := range []string{"idle.example.com", "busy.example.com"} {
+		if err := l.Wait(ctx, name); err != nil {
+			t.Fatalf("Wait(%q) error = %v", name, err)
+		}
+	}
+	l.Backoff("overloaded.example.com", 3*l.idleTimeout)
+
+	// The second request to a host waits for the rate limit
+	done := make(chan error)
+	go func() {
+		done <- l.Wait(ctx, "busy.example.com")
+	}()
+	for waiting := 0; waiting == 0; {
+		time.Sleep(time.Millisecond)
+		l.mu.Lock()
+		waiting = l.hosts["busy.example.com"].waiting
+		l.mu.Unlock()
+	}
+
+	l.mu.Lock()
+	l.prune(time.Now().Add(l.idleTimeout / 2))
+	if len(l.hosts) != 3 {
+		t.Errorf("hosts after pruning before the idle timeout = %d, want 3", len(l.hosts))
+	}
+	l.prune(time.Now().Add(2 * l.idleTimeout))
+	_, idle := l.hosts["idle.example.com"]
+	_, busy := l.hosts["busy.example.com"]
+	_, overloaded := l.hosts["overloaded.example.com"]
+	l.mu.Unlock()
+
+	if idle {
+		t.Errorf("idle host was not pruned")
+	}
+	if !busy {
+		t.Errorf("host with a waiting request was pruned")
+	}
+	if !overloaded {
+		t.Errorf("host with a pending backoff was pruned")
+	}
+
+	cancel()
+	if err := <-done; err == nil {
+		t.Errorf("Wait() error = nil, want the context error")
+	}
+}
@@ -0,0 +1,225 @@
+package templates
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/intigriti/misconfig-mapper/internal/types"
+)
+
+// ServiceChange describes a service that was added, removed or changed by a template update
+type ServiceChange struct {
+	ID      int64    `json:"id"`
+	Service string   `json:"service"`
+	Fields  []string `json:"fields,omitempty"` // Changed fields, such as "request.path" or "response.fingerprints"
+	Rescan  bool     `json:"rescan,omitempty"` // The request, variables or matching changed, earlier results of the service may be outdated
+}
+
+// Diff lists the services that are added, removed and changed by a template update
+type Diff struct {
+	FromVersion int64           `json:"fromVersion,omitempty"` // Version of the installed templates, 0 if unknown
+	ToVersion   int64           `json:"toVersion,omitempty"`   // Version of the update, 0 if unknown
+	Added       []ServiceChange `json:"added"`
+	Removed     []ServiceChange `json:"removed"`
+	Changed     []ServiceChange `json:"changed"`
+}
+
+// Empty reports whether the update doesn't change any service
+func (d *Diff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// DiffServices compares two sets of services by ID
+func DiffServices(old, new []types.Service) *Diff {
+	diff := &Diff{
+		Added:   []ServiceChange{},
+		Removed: []ServiceChange{},
+		Changed: []ServiceChange{},
+	}
+
+	index := func(services []types.Service) map[int64]types.Service {
+		m := make(map[int64]types.Service, len(services))
+		for _, service := range services {
+			m[service.ID] = service
+		}
+		return m
+	}
+	oldServices, newServices := index(old), index(new)
+
+	for _, service := range new {
+		previous, ok := oldServices[service.ID]
+		if !ok {
+			diff.Added = append(diff.Added, ServiceChange{ID: service.ID, Service: service.Metadata.ServiceName})
+			continue
+		}
+
+		fields := changedFields(previous, service)
+		if len(fields) == 0 {
+			continue
+		}
+
+		diff.Changed = append(diff.Changed, ServiceChange{
+			ID:      service.ID,
+			Service: service.Metadata.ServiceName,
+			Fields:  fields,
+			Rescan: slices.ContainsFunc(fields, func(field string) bool {
+				return strings.HasPrefix(field, "request") || strings.HasPrefix(field, "response") || field == "variables"
+			}),
+		})
+	}
+
+	for _, service := range old {
+		if _, ok := newServices[service.ID]; !ok {
+			diff.Removed = append(diff.Removed, ServiceChange{ID: service.ID, Service: service.Metadata.ServiceName})
+		}
+	}
+
+	for _, changes := range [][]ServiceChange{diff.Added, diff.Removed, diff.Changed} {
+		slices.SortFunc(changes, func(a, b ServiceChange) int {
+			return cmp.Compare(a.ID, b.ID)
+		})
+	}
+
+	return diff
+}
+
+// changedFields returns the fields of a template that differ, up to the second level (such as "request.path")
+func changedFields(old, new types.Service) []string {
+	toMap := func(service types.Service) map[string]any {
+		var m map[string]any
+		data, _ := json.Marshal(service)
+		_ = json.Unmarshal(data, &m)
+		return m
+	}
+	a, b := toMap(old), toMap(new)
+
+	var fields []string
+	for _, key := range unionKeys(a, b) {
+		oldSection, oldOK := a[key].(map[string]any)
+		newSection, newOK := b[key].(map[string]any)
+		if !oldOK || !newOK {
+			if !reflect.DeepEqual(a[key], b[key]) {
+				fields = append(fields, key)
+			}
+			continue
+		}
+
+		for _, field := range unionKeys(oldSection, newSection) {
+			if !reflect.DeepEqual(oldSection[field], newSection[field]) {
+				fields = append(fields, key+"."+field)
+			}
+		}
+	}
+
+	return fields
+}
+
+// unionKeys returns the sorted keys of two maps
+func unionKeys(a, b map[string]any) []string {
+	var keys []string
+	for key := range a {
+		keys = append(keys, key)
+	}
+	for key := range b {
+		if _, ok := a[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+
+	return keys
+}
+
+// loadFiles loads the templates of the listed files of a directory, skipping fixtures and missing files
+func loadFiles(dir string, names []string) ([]types.Service, error) {
+	var services []types.Service
+
+	for _, name := range names {
+		if name == manifestFile || strings.HasPrefix(name, fixturesDir+"/") ||
+			!slices.Contains(templateExtensions, strings.ToLower(path.Ext(name))) {
+			continue
+		}
+
+		file := filepath.Join(dir, filepath.FromSlash(name))
+		if _, err := os.Stat(file); os.IsNotExist(err) {
+			continue
+		}
+
+		loaded, err := LoadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		services = append(services, loaded...)
+	}
+
+	return services, nil
+}
+
+// parseFiles parses the templates of the listed files of an update, skipping fixtures
+func parseFiles(files map[string][]byte, names []string) ([]types.Service, error) {
+	var services []types.Service
+
+	for _, name := range names {
+		if name == manifestFile || strings.HasPrefix(name, fixturesDir+"/") ||
+			!slices.Contains(templateExtensions, strings.ToLower(path.Ext(name))) {
+			continue
+		}
+
+		parsed, err := parseFile(name, files[name])
+		if err != nil {
+			return nil, err
+		}
+		services = append(services, parsed...)
+	}
+
+	return services, nil
+}
+
+// PrintDiff prints the changes of a template update, as JSON if requested
+func (m *Manager) PrintDiff(diff *Diff, jsonOutput bool) {
+	if jsonOutput {
+		data, err := json.Marshal(diff)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to marshal template changes %v", err)
+			return
+		}
+		fmt.Println(string(data))
+		return
+	}
+
+	if diff.Empty() {
+		if m.Verbosity >= types.Normal {
+			fmt.Println("[+] Info: No services were added, removed or changed")
+		}
+		return
+	}
+
+	if diff.FromVersion > 0 && diff.ToVersion > 0 {
+		fmt.Printf("[+] Template changes (version %d => %d):\n", diff.FromVersion, diff.ToVersion)
+	} else {
+		fmt.Println("[+] Template changes:")
+	}
+
+	for _, change := range diff.Added {
+		fmt.Printf("  + %-2d %s (added)\n", change.ID, change.Service)
+	}
+	for _, change := range diff.Removed {
+		fmt.Printf("  - %-2d %s (removed)\n", change.ID, change.Service)
+	}
+	for _, change := range diff.Changed {
+		rescan := ""
+		if change.Rescan {
+			rescan = " (rescan recommended)"
+		}
+		fmt.Printf("  ~ %-2d %s: %s%s\n", change.ID, change.Service, strings.Join(change.Fields, ", "), rescan)
+	}
+
+	fmt.Printf("[+] %d added, %d removed, %d changed service(s)\n", len(diff.Added), len(diff.Removed), len(diff.Changed))
+}
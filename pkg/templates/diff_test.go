@@ -0,0 +1,159 @@
+package templates
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"os"
+	"slices"
+	"testing"
+
+	"github.com/intigriti/misconfig-mapper/internal/types"
+)
+
+func TestDiffServices(t *testing.T) {
+	service := func(id int64, modify func(*types.Service)) types.Service {
+		s := validService()
+		s.ID = id
+		if modify != nil {
+			modify(&s)
+		}
+		return s
+	}
+
+	old := []types.Service{
+		service(0, nil),
+		service(1, nil),
+		service(2, nil),
+		service(3, nil),
+	}
+	new := []types.Service{
+		service(4, nil),
+		service(3, func(s *types.Service) { s.Metadata.Description = "Example service" }),
+		service(2, func(s *types.Service) {
+			s.Request.Path = []string{"/", "/login"}
+			s.Response.Fingerprints = []string{"Sign in"}
+		}),
+		service(0, nil),
+	}
+
+	diff := DiffServices(old, new)
+
+	if got := changeIDs(diff.Added); !slices.Equal(got, []int64{4}) {
+		t.Errorf("added = %v, want [4]", got)
+	}
+	if got := changeIDs(diff.Removed); !slices.Equal(got, []int64{1}) {
+		t.Errorf("removed = %v, want [1]", got)
+	}
+	if got := changeIDs(diff.Changed); !slices.Equal(got, []int64{2, 3}) {
+		t.Fatalf("changed = %v, want [2 3]", got)
+	}
+
+	tests := []struct {
+		change ServiceChange
+		fields []string
+		rescan bool
+	}{
+		{change: diff.Changed[0], fields: []string{"request.path", "response.fingerprints"}, rescan: true},
+		{change: diff.Changed[1], fields: []string{"metadata.description"}},
+	}
+	for _, tt := range tests {
+		if !slices.Equal(tt.change.Fields, tt.fields) {
+			t.Errorf("service %d fields = %v, want %v", tt.change.ID, tt.change.Fields, tt.fields)
+		}
+		if tt.change.Rescan != tt.rescan {
+			t.Errorf("service %d rescan = %v, want %v", tt.change.ID, tt.change.Rescan, tt.rescan)
+		}
+	}
+
+	if !DiffServices(old, old).Empty() {
+		t.Errorf("DiffServices() of the same services is not empty")
+	}
+}
+
+func TestChangedFields(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*types.Service)
+		want   []string
+	}{
+		{
+			name:   "unchanged",
+			modify: func(s *types.Service) {},
+		},
+		{
+			name: "variables",
+			modify: func(s *types.Service) {
+				s.Variables = map[string][]string{"region": {"eu"}}
+			},
+			want: []string{"variables"},
+		},
+		{
+			name: "nested fields",
+			modify: func(s *types.Service) {
+				s.Request.Method = "POST"
+				s.Request.Headers = []map[string]string{{"Accept": "*/*"}}
+				s.Metadata.Severity = "high"
+			},
+			want: []string{"metadata.severity", "request.headers", "request.method"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			old, new := validService(), validService()
+			tt.modify(&new)
+
+			if got := changedFields(old, new); !slices.Equal(got, tt.want) {
+				t.Errorf("changedFields() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpdateTemplatesDryRun(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	if _, err := updateManager(t, dir, writeRelease(t, 1, private, map[string][]int64{servicesFile: {0, 1}}), public).UpdateTemplates(context.Background(), false); err != nil {
+		t.Fatalf("UpdateTemplates() error = %v", err)
+	}
+	before, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := updateManager(t, dir, writeRelease(t, 2, private, map[string][]int64{servicesFile: {0, 2}}), public)
+	diff, err := m.UpdateTemplates(context.Background(), true)
+	if err != nil {
+		t.Fatalf("UpdateTemplates() error = %v", err)
+	}
+
+	if diff.FromVersion != 1 || diff.ToVersion != 2 {
+		t.Errorf("versions = %d => %d, want 1 => 2", diff.FromVersion, diff.ToVersion)
+	}
+	if got := changeIDs(diff.Added); !slices.Equal(got, []int64{2}) {
+		t.Errorf("added = %v, want [2]", got)
+	}
+	if got := changeIDs(diff.Removed); !slices.Equal(got, []int64{1}) {
+		t.Errorf("removed = %v, want [1]", got)
+	}
+
+	// A dry run doesn't write anything
+	after, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(after) != len(before) {
+		t.Errorf("dry run changed the templates folder from %d to %d entries", len(before), len(after))
+	}
+	if got := loadedIDs(t, dir); !slices.Equal(got, []int64{0, 1}) {
+		t.Errorf("loaded services = %v, want [0 1]", got)
+	}
+	if got := installedVersion(t, dir); got != 1 {
+		t.Errorf("installed version = %d, want 1", got)
+	}
+}
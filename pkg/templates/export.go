@@ -0,0 +1,63 @@
+package templates
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Export writes the files of a template bundle, such as the embedded templates, to a directory.
+// Existing files are only overwritten if forced, it returns the number of written files.
+func Export(bundle fs.FS, dir string, force bool) (int, error) {
+	var existing []string
+	err := fs.WalkDir(bundle, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(name))); err == nil {
+			existing = append(existing, filepath.Join(dir, filepath.FromSlash(name)))
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if len(existing) > 0 && !force {
+		return 0, fmt.Errorf("%d file(s) already exist, such as %s (use -force to overwrite them)", len(existing), existing[0])
+	}
+
+	var written int
+	err = fs.WalkDir(bundle, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		file := filepath.Join(dir, filepath.FromSlash(name))
+		if d.IsDir() {
+			return os.MkdirAll(file, 0755)
+		}
+
+		data, err := fs.ReadFile(bundle, name)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(file, data, 0644); err != nil {
+			return err
+		}
+		written++
+
+		return nil
+	})
+	if err != nil {
+		return written, fmt.Errorf("failed to export templates: %w", err)
+	}
+
+	if written == 0 {
+		return 0, errors.New("no templates to export")
+	}
+
+	return written, nil
+}
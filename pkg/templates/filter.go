@@ -0,0 +1,139 @@
+package templates
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/intigriti/misconfig-mapper/internal/types"
+)
+
+// Severities are the supported template severities, from lowest to highest
+var Severities = []string{"info", "low", "medium", "high", "critical"}
+
+// Filter narrows down the selected services by their tags, severity and exclusions
+type Filter struct {
+	Tags        [][]string // Tag expression: services match if they match all tags of any group, tags prefixed with "!" must be absent
+	Severity    string     // Minimum severity, empty for any severity
+	ExcludeIDs  []string   // Service IDs, names or name globs to exclude
+	ExcludeTags []string   // Services with any of these tags are excluded
+}
+
+// NewFilter parses the tag expression, severity threshold and exclusions of a filter.
+// In a tag expression, commas separate alternatives and "+" combines required tags, i.e. "storage,ci+!azure".
+func NewFilter(tags, severity, excludeIDs, excludeTags string) (*Filter, error) {
+	filter := &Filter{
+		Severity:    strings.ToLower(strings.TrimSpace(severity)),
+		ExcludeIDs:  splitList(excludeIDs),
+		ExcludeTags: splitList(strings.ToLower(excludeTags)),
+	}
+
+	for _, group := range splitList(strings.ToLower(tags)) {
+		var terms []string
+		for _, term := range strings.Split(group, "+") {
+			term = strings.TrimSpace(term)
+			if term == "" || term == "!" {
+				return nil, fmt.Errorf("invalid tag expression %q", tags)
+			}
+			terms = append(terms, term)
+		}
+		filter.Tags = append(filter.Tags, terms)
+	}
+
+	if filter.Severity != "" && !slices.Contains(Severities, filter.Severity) {
+		return nil, fmt.Errorf("invalid severity %q (must be one of %s)", severity, strings.Join(Severities, ", "))
+	}
+
+	return filter, nil
+}
+
+// Empty reports whether the filter selects every service
+func (f *Filter) Empty() bool {
+	return len(f.Tags) == 0 && f.Severity == "" && len(f.ExcludeIDs) == 0 && len(f.ExcludeTags) == 0
+}
+
+// Match reports whether a service passes the filter
+func (f *Filter) Match(service types.Service) bool {
+	tags := make([]string, 0, len(service.Metadata.Tags))
+	for _, tag := range service.Metadata.Tags {
+		tags = append(tags, strings.ToLower(tag))
+	}
+
+	if len(f.Tags) > 0 && !slices.ContainsFunc(f.Tags, func(group []string) bool {
+		for _, term := range group {
+			if negated, ok := strings.CutPrefix(term, "!"); ok {
+				if slices.Contains(tags, negated) {
+					return false
+				}
+			} else if !slices.Contains(tags, term) {
+				return false
+			}
+		}
+		return true
+	}) {
+		return false
+	}
+
+	if f.Severity != "" && SeverityLevel(service.Metadata.Severity) < SeverityLevel(f.Severity) {
+		return false
+	}
+
+	if slices.ContainsFunc(f.ExcludeTags, func(tag string) bool { return slices.Contains(tags, tag) }) {
+		return false
+	}
+
+	return !slices.ContainsFunc(f.ExcludeIDs, func(id string) bool { return matchService(service, id) })
+}
+
+// SeverityLevel returns the rank of a severity, services without a (valid) severity rank lowest
+func SeverityLevel(severity string) int {
+	return slices.Index(Severities, strings.ToLower(severity))
+}
+
+// FilterServices returns the services that pass a filter
+func (m *Manager) FilterServices(services []types.Service, filter *Filter) []types.Service {
+	if filter == nil || filter.Empty() {
+		return services
+	}
+
+	var result []types.Service
+	for _, service := range services {
+		if filter.Match(service) {
+			result = append(result, service)
+		}
+	}
+
+	return result
+}
+
+// matchService reports whether a service has an ID, or a name or service slug that matches a (glob) pattern
+func matchService(service types.Service, pattern string) bool {
+	if fmt.Sprintf("%v", service.ID) == pattern {
+		return true
+	}
+
+	pattern = strings.ToLower(pattern)
+	for _, name := range []string{service.Metadata.Service, service.Metadata.ServiceName} {
+		name = strings.ToLower(name)
+		if name == pattern {
+			return true
+		}
+		if matched, err := path.Match(pattern, name); err == nil && matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+// splitList splits a comma separated list and drops empty values
+func splitList(s string) []string {
+	var values []string
+	for _, value := range strings.Split(s, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
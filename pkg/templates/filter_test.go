@@ -0,0 +1,150 @@
+package templates
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/intigriti/misconfig-mapper/internal/types"
+)
+
+// filterServices returns templates with different services, tags and severities to filter
+func filterServices() []types.Service {
+	service := func(id int64, slug, name, severity string, tags ...string) types.Service {
+		s := validService()
+		s.ID = id
+		s.Metadata.Service = slug
+		s.Metadata.ServiceName = name
+		s.Metadata.Severity = severity
+		s.Metadata.Tags = tags
+		return s
+	}
+
+	return []types.Service{
+		service(0, "atlassian", "Atlassian Jira Open Signups", "medium", "atlassian", "jira", "signup"),
+		service(1, "atlassian", "Atlassian Confluence Open Signups", "medium", "atlassian", "confluence", "signup"),
+		service(2, "aws-s3", "AWS S3 Bucket Listing", "high", "Storage", "aws"),
+		service(3, "azure-blob", "Azure Blob Storage Listing", "high", "storage", "azure"),
+		service(4, "jenkins", "Jenkins Open Signups", "critical", "ci", "signup"),
+		service(5, "azure-devops", "Azure DevOps", "info", "ci", "azure"),
+		service(6, "workday", "Workday", ""),
+	}
+}
+
+func TestFilterServices(t *testing.T) {
+	tests := []struct {
+		name        string
+		tags        string
+		severity    string
+		excludeIDs  string
+		excludeTags string
+		want        []int64
+	}{
+		{
+			name: "empty filter",
+			want: []int64{0, 1, 2, 3, 4, 5, 6},
+		},
+		{
+			name: "any tag",
+			tags: "storage,ci",
+			want: []int64{2, 3, 4, 5},
+		},
+		{
+			name: "all tags",
+			tags: "signup+ci",
+			want: []int64{4},
+		},
+		{
+			name: "negated tag",
+			tags: "storage,ci+!azure",
+			want: []int64{2, 3, 4},
+		},
+		{
+			name:     "minimum severity",
+			severity: "HIGH",
+			want:     []int64{2, 3, 4},
+		},
+		{
+			name:     "tags and severity",
+			tags:     "signup",
+			severity: "critical",
+			want:     []int64{4},
+		},
+		{
+			name:       "exclude IDs and names",
+			excludeIDs: "0, workday, azure*",
+			want:       []int64{1, 2, 4},
+		},
+		{
+			name:        "exclude tags",
+			excludeTags: "AZURE,jira",
+			want:        []int64{1, 2, 4, 6},
+		},
+		{
+			name:        "include and exclude",
+			tags:        "signup",
+			excludeIDs:  "4",
+			excludeTags: "confluence",
+			want:        []int64{0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := NewFilter(tt.tags, tt.severity, tt.excludeIDs, tt.excludeTags)
+			if err != nil {
+				t.Fatalf("NewFilter() error = %v", err)
+			}
+
+			m := NewManager(nil, types.Silent)
+			if got := ids(m.FilterServices(filterServices(), filter)); !slices.Equal(got, tt.want) {
+				t.Errorf("FilterServices() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewFilterErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		tags     string
+		severity string
+		want     string
+	}{
+		{name: "empty tag", tags: "storage+", want: "invalid tag expression"},
+		{name: "empty negation", tags: "ci+!", want: "invalid tag expression"},
+		{name: "unknown severity", severity: "urgent", want: "invalid severity"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewFilter(tt.tags, tt.severity, "", "")
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("NewFilter() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetService(t *testing.T) {
+	tests := []struct {
+		ids  string
+		want []int64
+	}{
+		{ids: "*", want: []int64{0, 1, 2, 3, 4, 5, 6}},
+		{ids: "2,4", want: []int64{2, 4}},
+		{ids: "atlassian", want: []int64{0, 1}},
+		{ids: "Workday", want: []int64{6}},
+		{ids: "azure*, 0", want: []int64{0, 3, 5}},
+		{ids: "99"},
+	}
+
+	m := NewManager(nil, types.Silent)
+	for _, tt := range tests {
+		t.Run(tt.ids, func(t *testing.T) {
+			if got := ids(m.GetService(tt.ids, filterServices())); !slices.Equal(got, tt.want) {
+				t.Errorf("GetService(%q) = %v, want %v", tt.ids, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package templates
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Fixture expectations
+const (
+	ExpectPositive = "positive" // The response matches (detection or vulnerability)
+	ExpectNegative = "negative" // The response does not match
+	ExpectExcluded = "excluded" // The response matches an exclusion pattern
+)
+
+// Fixture phases
+const (
+	PhaseDetection     = "detection"     // The response is checked against the detection fingerprints
+	PhaseVulnerability = "vulnerability" // The response is checked against the misconfiguration fingerprints
+)
+
+// Fixture is a recorded HTTP response of a service together with the expected outcome of checking it.
+// Fixtures are stored in the "fixtures/<service ID>/" folder of a templates directory, one JSON or YAML file per fixture.
+type Fixture struct {
+	Name        string            `json:"name,omitempty"`        // Defaults to the file name
+	Description string            `json:"description,omitempty"` // What the recorded response represents
+	Expect      string            `json:"expect"`                // positive, negative or excluded
+	Phase       string            `json:"phase,omitempty"`       // detection or vulnerability (default)
+	Target      string            `json:"target,omitempty"`      // Value of the {TARGET} placeholder, defaults to "example"
+	Path        string            `json:"path,omitempty"`        // Requested path, defaults to the detection path or the first path
+	Variables   map[string]string `json:"variables,omitempty"`   // Values of the template variables, defaults to the first declared values
+	Response    FixtureResponse   `json:"response"`
+	File        string            `json:"-"`
+}
+
+// FixtureResponse is the recorded response that is served for every request of a fixture
+type FixtureResponse struct {
+	StatusCode int               `json:"statusCode,omitempty"` // Defaults to 200
+	Headers    map[string]string `json:"headers,omitempty"`
+	Body       string            `json:"body,omitempty"`
+}
+
+// LoadFixtures loads the fixtures of all services in the fixtures folders of the embedded templates and all templates directories.
+// Fixtures of later directories replace the fixtures of a service in the embedded templates and earlier directories.
+func (m *Manager) LoadFixtures() (map[int64][]Fixture, error) {
+	fixtures := make(map[int64][]Fixture)
+
+	for _, l := range m.layers() {
+		loaded, err := l.fixtures()
+		if err != nil {
+			return nil, err
+		}
+		maps.Copy(fixtures, loaded)
+	}
+
+	return fixtures, nil
+}
+
+// fixtures loads the fixtures of all services in the fixtures folder of a layer, a layer without a fixtures folder has no fixtures
+func (l layer) fixtures() (map[int64][]Fixture, error) {
+	fixtures := make(map[int64][]Fixture)
+
+	entries, err := fs.ReadDir(l.fsys, fixturesDir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return fixtures, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed reading fixtures folder '%s': %w", l.path(fixturesDir), err)
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+
+		dir := path.Join(fixturesDir, entry.Name())
+
+		id, err := strconv.ParseInt(entry.Name(), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("fixtures folder '%s' is not named after a service ID", l.path(dir))
+		}
+
+		files, err := fs.ReadDir(l.fsys, dir)
+		if err != nil {
+			return nil, fmt.Errorf("failed reading fixtures folder '%s': %w", l.path(dir), err)
+		}
+
+		for _, file := range files {
+			if file.IsDir() || !slices.Contains(templateExtensions, strings.ToLower(path.Ext(file.Name()))) {
+				continue
+			}
+
+			name := path.Join(dir, file.Name())
+			data, err := fs.ReadFile(l.fsys, name)
+			if err != nil {
+				return nil, fmt.Errorf("failed opening file '%s': %w", l.path(name), err)
+			}
+
+			fixture, err := parseFixture(l.path(name), data)
+			if err != nil {
+				return nil, err
+			}
+			fixtures[id] = append(fixtures[id], *fixture)
+		}
+	}
+
+	return fixtures, nil
+}
+
+// parseFixture decodes a JSON or YAML fixture file
+func parseFixture(path string, data []byte) (*Fixture, error) {
+	data, err := toJSON(path, data)
+	if err != nil {
+		return nil, err
+	}
+
+	var fixture Fixture
+	if err := json.Unmarshal(data, &fixture); err != nil {
+		return nil, fmt.Errorf("failed decoding fixture file '%s': %w", path, err)
+	}
+	fixture.File = path
+
+	if fixture.Name == "" {
+		fixture.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	if fixture.Phase == "" {
+		fixture.Phase = PhaseVulnerability
+	}
+	if fixture.Target == "" {
+		fixture.Target = "example"
+	}
+	if fixture.Response.StatusCode == 0 {
+		fixture.Response.StatusCode = 200
+	}
+
+	if !slices.Contains([]string{ExpectPositive, ExpectNegative, ExpectExcluded}, fixture.Expect) {
+		return nil, fmt.Errorf("fixture file '%s': invalid expectation %q (must be %s, %s or %s)",
+			path, fixture.Expect, ExpectPositive, ExpectNegative, ExpectExcluded)
+	}
+	if fixture.Phase != PhaseDetection && fixture.Phase != PhaseVulnerability {
+		return nil, fmt.Errorf("fixture file '%s': invalid phase %q (must be %s or %s)",
+			path, fixture.Phase, PhaseDetection, PhaseVulnerability)
+	}
+
+	return &fixture, nil
+}
@@ -0,0 +1,154 @@
+package templates
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/intigriti/misconfig-mapper/internal/types"
+)
+
+func TestParseFixture(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    string
+		data    string
+		want    Fixture
+		wantErr string
+	}{
+		{
+			name: "defaults",
+			path: "fixtures/0/login-page.json",
+			data: `{"expect": "positive"}`,
+			want: Fixture{
+				Name:     "login-page",
+				Expect:   ExpectPositive,
+				Phase:    PhaseVulnerability,
+				Target:   "example",
+				Response: FixtureResponse{StatusCode: 200},
+			},
+		},
+		{
+			name: "YAML",
+			path: "fixtures/0/not-found.yml",
+			data: "name: missing tenant\nexpect: negative\nphase: detection\ntarget: acme\nresponse:\n  statusCode: 404\n  body: Not Found\n",
+			want: Fixture{
+				Name:     "missing tenant",
+				Expect:   ExpectNegative,
+				Phase:    PhaseDetection,
+				Target:   "acme",
+				Response: FixtureResponse{StatusCode: 404, Body: "Not Found"},
+			},
+		},
+		{
+			name:    "missing expectation",
+			path:    "fixtures/0/a.json",
+			data:    `{"response": {"body": "Welcome"}}`,
+			wantErr: "invalid expectation",
+		},
+		{
+			name:    "invalid phase",
+			path:    "fixtures/0/a.json",
+			data:    `{"expect": "positive", "phase": "login"}`,
+			wantErr: "invalid phase",
+		},
+		{
+			name:    "invalid JSON",
+			path:    "fixtures/0/a.json",
+			data:    `{"expect": `,
+			wantErr: "failed decoding fixture file",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fixture, err := parseFixture(tt.path, []byte(tt.data))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("parseFixture() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseFixture() error = %v", err)
+			}
+
+			tt.want.File = tt.path
+			if fixture.Name != tt.want.Name || fixture.Expect != tt.want.Expect || fixture.Phase != tt.want.Phase ||
+				fixture.Target != tt.want.Target || fixture.File != tt.want.File ||
+				fixture.Response.StatusCode != tt.want.Response.StatusCode || fixture.Response.Body != tt.want.Response.Body {
+				t.Errorf("parseFixture() = %+v, want %+v", *fixture, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadFixtures(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"fixtures/1/private.yaml": "expect: negative\n",
+		"fixtures/2/open.json":    `{"expect": "positive"}`,
+		"fixtures/2/README.md":    "Not a fixture",
+	} {
+		file := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	m := NewManager([]string{dir}, types.Silent)
+	m.SetEmbedded(fstest.MapFS{
+		"fixtures/0/signup.json": {Data: []byte(`{"expect": "positive"}`)},
+		"fixtures/1/signup.json": {Data: []byte(`{"expect": "positive"}`)},
+		"fixtures/1/closed.json": {Data: []byte(`{"expect": "negative"}`)},
+	})
+
+	fixtures, err := m.LoadFixtures()
+	if err != nil {
+		t.Fatalf("LoadFixtures() error = %v", err)
+	}
+
+	names := func(fixtures []Fixture) []string {
+		var names []string
+		for _, fixture := range fixtures {
+			names = append(names, fixture.Name)
+		}
+		return names
+	}
+
+	// The fixtures of a templates folder replace the embedded fixtures of the same service
+	want := map[int64][]string{
+		0: {"signup"},
+		1: {"private"},
+		2: {"open"},
+	}
+	if len(fixtures) != len(want) {
+		t.Errorf("LoadFixtures() loaded fixtures of %d services, want %d", len(fixtures), len(want))
+	}
+	for id, wantNames := range want {
+		if got := names(fixtures[id]); !slices.Equal(got, wantNames) {
+			t.Errorf("fixtures of service %d = %v, want %v", id, got, wantNames)
+		}
+	}
+	if got, want := fixtures[1][0].File, filepath.Join(dir, "fixtures", "1", "private.yaml"); got != want {
+		t.Errorf("fixture file = %q, want %q", got, want)
+	}
+}
+
+func TestLoadFixturesInvalidFolder(t *testing.T) {
+	m := NewManager(nil, types.Silent)
+	m.SetEmbedded(fstest.MapFS{
+		"fixtures/jira/signup.json": {Data: []byte(`{"expect": "positive"}`)},
+	})
+
+	_, err := m.LoadFixtures()
+	if err == nil || !strings.Contains(err.Error(), "is not named after a service ID") {
+		t.Fatalf("LoadFixtures() error = %v, want an invalid folder error", err)
+	}
+}
@@ -0,0 +1,212 @@
+package templates
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/intigriti/misconfig-mapper/internal/types"
+	"gopkg.in/yaml.v3"
+)
+
+// fixturesDir is the name of the directories that hold template fixtures instead of templates
+const fixturesDir = "fixtures"
+
+// templateExtensions are the extensions of the files that are loaded as templates
+var templateExtensions = []string{".json", ".yaml", ".yml"}
+
+// layer is a set of templates, the templates of directory layers override the templates of the layers below
+type layer struct {
+	dir  string // Templates directory, empty for the embedded templates
+	fsys fs.FS
+}
+
+// path returns the path of a file in the layer, as displayed to the user
+func (l layer) path(name string) string {
+	if l.dir == "" {
+		return "embedded:" + name
+	}
+	return filepath.Join(l.dir, filepath.FromSlash(name))
+}
+
+// String returns the name of the layer
+func (l layer) String() string {
+	if l.dir == "" {
+		return "embedded templates"
+	}
+	return l.dir
+}
+
+// layers returns the embedded templates followed by the templates directories that exist.
+// Installed templates replace the embedded templates, so services removed by an update are no longer loaded.
+func (m *Manager) layers() []layer {
+	var layers []layer
+	if m.Embedded != nil && !m.installed() {
+		layers = append(layers, layer{fsys: m.Embedded})
+	}
+
+	for _, dir := range m.TemplatesDirs {
+		if _, err := os.Stat(dir); err != nil {
+			// The default templates folder is optional, the embedded templates are used instead
+			if filepath.Clean(dir) != filepath.Clean(DefaultDir) && m.Verbosity >= types.Normal {
+				fmt.Fprintf(os.Stderr, "[-] Warning: Skipping templates folder %s (%v)\n", dir, err)
+			}
+			continue
+		}
+		layers = append(layers, layer{dir: dir, fsys: os.DirFS(dir)})
+	}
+
+	return layers
+}
+
+// installed reports whether templates are installed in the primary templates directory, by an update or an export
+func (m *Manager) installed() bool {
+	if m.TemplatesDir == "" {
+		return false
+	}
+
+	for _, name := range []string{manifestFile, servicesFile} {
+		if _, err := os.Stat(filepath.Join(m.TemplatesDir, name)); err == nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+// load recursively loads all template files of a layer and returns the files that define each service ID
+func (l layer) load() ([]types.Service, map[int64][]string, error) {
+	var services []types.Service
+	sources := make(map[int64][]string)
+
+	err := fs.WalkDir(l.fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			// Skip fixtures and hidden directories (such as .git)
+			if name != "." && (d.Name() == fixturesDir || strings.HasPrefix(d.Name(), ".")) {
+				return fs.SkipDir
+			}
+			return nil
+		}
+
+		if d.Name() == manifestFile || !slices.Contains(templateExtensions, strings.ToLower(path.Ext(name))) {
+			return nil
+		}
+
+		data, err := fs.ReadFile(l.fsys, name)
+		if err != nil {
+			return fmt.Errorf("failed opening file '%s': %w", l.path(name), err)
+		}
+
+		loaded, err := parseFile(l.path(name), data)
+		if err != nil {
+			return err
+		}
+
+		for _, service := range loaded {
+			sources[service.ID] = append(sources[service.ID], l.path(name))
+		}
+		services = append(services, loaded...)
+
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return services, sources, nil
+}
+
+// LoadFile loads the templates of a JSON or YAML file, which holds a single template or a list of templates
+func LoadFile(path string) ([]types.Service, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed opening file '%s': %w", path, err)
+	}
+
+	return parseFile(path, data)
+}
+
+// parseFile decodes the templates of a JSON or YAML file
+func parseFile(path string, data []byte) ([]types.Service, error) {
+	data, err := toJSON(path, data)
+	if err != nil {
+		return nil, err
+	}
+
+	services, err := decodeTemplates(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed decoding template file '%s': %w", path, err)
+	}
+
+	return services, nil
+}
+
+// toJSON converts the content of a YAML file to JSON, to share the JSON field names of the template types.
+// The content of other files is returned as is.
+func toJSON(path string, data []byte) ([]byte, error) {
+	if ext := strings.ToLower(filepath.Ext(path)); ext != ".yaml" && ext != ".yml" {
+		return data, nil
+	}
+
+	var document any
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("failed decoding YAML file '%s': %w", path, err)
+	}
+
+	data, err := json.Marshal(document)
+	if err != nil {
+		return nil, fmt.Errorf("failed decoding YAML file '%s': %w", path, err)
+	}
+
+	return data, nil
+}
+
+// decodeTemplates decodes a JSON template or a JSON list of templates
+func decodeTemplates(data []byte) ([]types.Service, error) {
+	var services []types.Service
+
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '{' {
+		var service types.Service
+		if err := json.Unmarshal(data, &service); err != nil {
+			return nil, err
+		}
+		return append(services, service), nil
+	}
+
+	if err := json.Unmarshal(data, &services); err != nil {
+		return nil, err
+	}
+
+	return services, nil
+}
+
+// duplicates reports every service ID that is defined more than once, with the files that define it
+func duplicates(sources map[int64][]string) error {
+	var errs []error
+
+	ids := make([]int64, 0, len(sources))
+	for id := range sources {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+
+	for _, id := range ids {
+		if files := sources[id]; len(files) > 1 {
+			errs = append(errs, fmt.Errorf("duplicate service ID %d defined in %s", id, strings.Join(files, " and ")))
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,138 @@
+package templates
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/intigriti/misconfig-mapper/internal/types"
+)
+
+// yamlTemplate is a single valid YAML template for service 3
+const yamlTemplate = `id: 3
+request:
+  method: GET
+  baseURL: https://{TARGET}.example.com
+  path:
+    - /
+response:
+  statusCode: 200
+  fingerprints:
+    - Welcome
+metadata:
+  service: example
+  serviceName: Example 3
+`
+
+func TestLoadTemplates(t *testing.T) {
+	dir := t.TempDir()
+	writeServices(t, filepath.Join(dir, servicesFile), 0, 1)
+	writeServices(t, filepath.Join(dir, "custom", "list.json"), 2)
+	if err := os.WriteFile(filepath.Join(dir, "custom", "single.yaml"), []byte(yamlTemplate), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Fixtures, hidden folders, the manifest and other files aren't templates
+	writeServices(t, filepath.Join(dir, fixturesDir, "0", "fixture.json"), 10)
+	writeServices(t, filepath.Join(dir, ".git", "template.json"), 11)
+	writeServices(t, filepath.Join(dir, manifestFile), 12)
+	writeServices(t, filepath.Join(dir, "notes.txt"), 13)
+
+	m := NewManager([]string{dir}, types.Silent)
+	services, err := m.LoadTemplates()
+	if err != nil {
+		t.Fatalf("LoadTemplates() error = %v", err)
+	}
+
+	if got, want := ids(services), []int64{0, 1, 2, 3}; !slices.Equal(got, want) {
+		t.Errorf("LoadTemplates() = %v, want %v", got, want)
+	}
+	if got := services[3].Metadata.ServiceName; got != "Example 3" {
+		t.Errorf("YAML template service name = %q, want %q", got, "Example 3")
+	}
+	if got, want := m.Sources[3], []string{filepath.Join(dir, "custom", "single.yaml")}; !slices.Equal(got, want) {
+		t.Errorf("Sources[3] = %v, want %v", got, want)
+	}
+}
+
+func TestLoadTemplatesErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  string
+		is    error // Error that the returned error wraps, if any
+	}{
+		{
+			name:  "no templates",
+			files: map[string]string{"README.md": "# Templates"},
+			want:  "no templates found",
+			is:    ErrNoTemplates,
+		},
+		{
+			name:  "invalid JSON",
+			files: map[string]string{"broken.json": "[{"},
+			want:  "failed decoding template file",
+		},
+		{
+			name:  "invalid YAML",
+			files: map[string]string{"broken.yaml": "id: [3"},
+			want:  "failed decoding YAML file",
+		},
+		{
+			name:  "duplicate ID in a folder",
+			files: map[string]string{"a.json": `{"id": 3}`, "b/c.yml": yamlTemplate},
+			want:  "duplicate service ID 3 defined in",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range tt.files {
+				file := filepath.Join(dir, filepath.FromSlash(name))
+				if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			_, err := NewManager([]string{dir}, types.Silent).LoadTemplates()
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("LoadTemplates() error = %v, want %q", err, tt.want)
+			}
+			if tt.is != nil && !errors.Is(err, tt.is) {
+				t.Errorf("LoadTemplates() error = %v, want %v", err, tt.is)
+			}
+		})
+	}
+}
+
+func TestDecodeTemplates(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want []int64
+	}{
+		{name: "single template", data: `{"id": 4}`, want: []int64{4}},
+		{name: "list of templates", data: `[{"id": 4}, {"id": 5}]`, want: []int64{4, 5}},
+		{name: "leading whitespace", data: "\n  {\"id\": 4}", want: []int64{4}},
+		{name: "empty list", data: `[]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			services, err := decodeTemplates([]byte(tt.data))
+			if err != nil {
+				t.Fatalf("decodeTemplates() error = %v", err)
+			}
+			if got := ids(services); !slices.Equal(got, tt.want) {
+				t.Errorf("decodeTemplates() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
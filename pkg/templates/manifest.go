@@ -0,0 +1,251 @@
+package templates
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+const (
+	manifestFile  = "manifest.json"     // Lists the version and checksums of the files of a template release
+	signatureFile = "manifest.json.sig" // Base64 encoded Ed25519 signature of the manifest file
+)
+
+// Manifest describes a template release: its version and the SHA-256 checksum of each of its files
+type Manifest struct {
+	Version int64             `json:"version"`
+	Files   map[string]string `json:"files"` // Slash separated path relative to the templates directory => hex encoded SHA-256 checksum
+}
+
+// ParseManifest decodes a manifest and checks that all of its file paths stay inside the templates directory
+func ParseManifest(data []byte) (*Manifest, error) {
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %w", err)
+	}
+
+	if manifest.Version < 1 {
+		return nil, fmt.Errorf("invalid manifest: version must be 1 or higher")
+	}
+	if len(manifest.Files) == 0 {
+		return nil, fmt.Errorf("invalid manifest: no files listed")
+	}
+
+	for name, checksum := range manifest.Files {
+		if name != path.Clean(name) || path.IsAbs(name) || strings.HasPrefix(name, "../") || name == ".." ||
+			name == manifestFile || name == signatureFile || strings.HasPrefix(name, previousDir+"/") {
+			return nil, fmt.Errorf("invalid manifest: illegal file path %q", name)
+		}
+		if sum, err := hex.DecodeString(checksum); err != nil || len(sum) != sha256.Size {
+			return nil, fmt.Errorf("invalid manifest: invalid SHA-256 checksum for %q", name)
+		}
+	}
+
+	return &manifest, nil
+}
+
+// Verify checks that the content of a file matches its checksum in the manifest
+func (manifest *Manifest) Verify(name string, data []byte) error {
+	checksum, ok := manifest.Files[name]
+	if !ok {
+		return fmt.Errorf("%s is not listed in the manifest", name)
+	}
+
+	sum := sha256.Sum256(data)
+	if !strings.EqualFold(hex.EncodeToString(sum[:]), checksum) {
+		return fmt.Errorf("checksum mismatch for %s", name)
+	}
+
+	return nil
+}
+
+// Names returns the sorted file paths of the manifest
+func (manifest *Manifest) Names() []string {
+	names := make([]string, 0, len(manifest.Files))
+	for name := range manifest.Files {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	return names
+}
+
+// InstalledManifest returns the manifest of the templates installed in the primary templates directory.
+// It returns nil if the templates were not installed from a manifest.
+func (m *Manager) InstalledManifest() (*Manifest, error) {
+	data, err := os.ReadFile(filepath.Join(m.TemplatesDir, manifestFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return ParseManifest(data)
+}
+
+// GenerateManifest writes a manifest of all template and fixture files of a templates directory.
+// The manifest is signed if a private key is given.
+func GenerateManifest(dir string, version int64, key ed25519.PrivateKey) (*Manifest, error) {
+	if version < 1 {
+		return nil, fmt.Errorf("invalid manifest version %d (must be 1 or higher)", version)
+	}
+
+	manifest := &Manifest{
+		Version: version,
+		Files:   make(map[string]string),
+	}
+
+	err := filepath.WalkDir(dir, func(file string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			if file != dir && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if d.Name() == manifestFile || !slices.Contains(templateExtensions, strings.ToLower(filepath.Ext(file))) {
+			return nil
+		}
+
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+
+		name, err := filepath.Rel(dir, file)
+		if err != nil {
+			return err
+		}
+
+		sum := sha256.Sum256(data)
+		manifest.Files[filepath.ToSlash(name)] = hex.EncodeToString(sum[:])
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(manifest.Files) == 0 {
+		return nil, fmt.Errorf("%w in %s", ErrNoTemplates, dir)
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "    ")
+	if err != nil {
+		return nil, err
+	}
+	data = append(data, '\n')
+
+	if err := os.WriteFile(filepath.Join(dir, manifestFile), data, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write manifest: %w", err)
+	}
+
+	if key != nil {
+		signature := base64.StdEncoding.EncodeToString(ed25519.Sign(key, data))
+		if err := os.WriteFile(filepath.Join(dir, signatureFile), []byte(signature+"\n"), 0644); err != nil {
+			return nil, fmt.Errorf("failed to write manifest signature: %w", err)
+		}
+	}
+
+	return manifest, nil
+}
+
+// VerifySignature checks the base64 encoded Ed25519 signature of a manifest
+func VerifySignature(key ed25519.PublicKey, manifest, signature []byte) error {
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(signature)))
+	if err != nil || len(sig) != ed25519.SignatureSize {
+		return fmt.Errorf("malformed manifest signature")
+	}
+
+	if !ed25519.Verify(key, manifest, sig) {
+		return fmt.Errorf("invalid manifest signature")
+	}
+
+	return nil
+}
+
+// LoadPublicKey loads a PEM encoded Ed25519 public key, such as one created with "openssl pkey -pubout"
+func LoadPublicKey(file string) (ed25519.PublicKey, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed opening key file '%s': %w", file, err)
+	}
+
+	key, err := ParsePublicKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key '%s': %w", file, err)
+	}
+
+	return key, nil
+}
+
+// ParsePublicKey parses a PEM encoded Ed25519 public key
+func ParsePublicKey(data []byte) (ed25519.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("not PEM encoded")
+	}
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	publicKey, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("not an Ed25519 key")
+	}
+
+	return publicKey, nil
+}
+
+// LoadPrivateKey loads a PEM encoded Ed25519 private key, such as one created with "openssl genpkey -algorithm ed25519"
+func LoadPrivateKey(file string) (ed25519.PrivateKey, error) {
+	block, err := readPEM(file)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed parsing private key '%s': %w", file, err)
+	}
+
+	privateKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("private key '%s' is not an Ed25519 key", file)
+	}
+
+	return privateKey, nil
+}
+
+// readPEM reads the first PEM block of a file
+func readPEM(file string) (*pem.Block, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed opening key file '%s': %w", file, err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("key file '%s' is not PEM encoded", file)
+	}
+
+	return block, nil
+}
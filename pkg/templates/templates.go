@@ -1,126 +1,108 @@
 package templates
 
 import (
-	"context"
-	"encoding/json"
+	"cmp"
+	"crypto/ed25519"
+	"errors"
 	"fmt"
-	"io"
-	"net/http"
+	"io/fs"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
-	"time"
 
 	"github.com/intigriti/misconfig-mapper/internal/types"
+	"github.com/intigriti/misconfig-mapper/pkg/matchers"
 )
 
-const (
-	templatesURL = "https://raw.githubusercontent.com/intigriti/misconfig-mapper/main/templates/services.json"
-)
+// DefaultDir is the templates folder used when no templates folders are specified
+const DefaultDir = "./templates"
+
+// ErrNoTemplates is returned when the templates directories don't contain any template
+var ErrNoTemplates = errors.New("no templates found")
 
 // Manager handles loading and updating service templates
 type Manager struct {
-	TemplatesDir string
-	ServicesPath string
-	Verbosity    types.VerbosityLevel
+	TemplatesDir  string             // Primary templates directory, updated with the latest templates
+	TemplatesDirs []string           // All templates directories, loaded in order
+	ServicesPath  string             // Path of the services.json file in the primary templates directory
+	Sources       map[int64][]string // Files that define each loaded service ID
+	Embedded      fs.FS              // Templates embedded in the binary, loaded until templates are installed
+	Source        string             // URL or local folder that templates are updated from
+	PublicKey     ed25519.PublicKey  // Key that template manifests must be signed with
+	SkipVerify    bool               // Accept template updates without a valid manifest signature
+	Verbosity     types.VerbosityLevel
 }
 
-// NewManager creates a new template manager
-func NewManager(templatesDir string, verbosity types.VerbosityLevel) *Manager {
-	return &Manager{
-		TemplatesDir: templatesDir,
-		ServicesPath: filepath.Join(templatesDir, "services.json"),
-		Verbosity:    verbosity,
+// NewManager creates a new template manager, the first templates directory is the primary one
+func NewManager(templatesDirs []string, verbosity types.VerbosityLevel) *Manager {
+	var templatesDir string
+	if len(templatesDirs) > 0 {
+		templatesDir = templatesDirs[0]
 	}
-}
 
-// LoadTemplates loads service templates from the services.json file
-func (m *Manager) LoadTemplates() ([]types.Service, error) {
-	var services []types.Service
-
-	file, err := os.Open(m.ServicesPath)
-	if err != nil {
-		return services, fmt.Errorf("failed opening file '%s': %w", m.ServicesPath, err)
-	}
-	defer file.Close()
-
-	decoder := json.NewDecoder(file)
-	if err := decoder.Decode(&services); err != nil {
-		return services, fmt.Errorf("failed decoding JSON file: %w", err)
+	return &Manager{
+		TemplatesDir:  templatesDir,
+		TemplatesDirs: templatesDirs,
+		ServicesPath:  filepath.Join(templatesDir, servicesFile),
+		Sources:       make(map[int64][]string),
+		Source:        DefaultSource,
+		Verbosity:     verbosity,
 	}
-
-	return services, nil
 }
 
-// UpdateTemplates updates the templates from the GitHub repository
-func (m *Manager) UpdateTemplates(update bool) error {
-	if m.Verbosity >= types.Normal {
-		fmt.Printf("[+] Info: Pulling latest services and saving in %v\n", m.ServicesPath)
-	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), 7*time.Second)
-	defer cancel()
-
-	req, err := http.NewRequestWithContext(ctx, "GET", templatesURL, nil)
-	if err != nil {
-		return err
-	}
-
-	client := http.Client{}
-	res, err := client.Do(req)
-	if err != nil {
-		return err
-	}
-	if res == nil {
-		return fmt.Errorf("empty response received")
-	}
-	defer res.Body.Close()
-
-	body, err := io.ReadAll(res.Body)
-	if err != nil {
-		return err
-	}
+// LoadTemplates recursively loads the JSON and YAML templates of all templates directories, on top of the embedded templates
+// if no templates are installed in the primary templates directory. Templates of the directories override the embedded
+// templates with the same service ID, but service IDs must be unique across all templates directories.
+func (m *Manager) LoadTemplates() ([]types.Service, error) {
+	byID := make(map[int64]types.Service)
+	m.Sources = make(map[int64][]string)
+	userSources := make(map[int64][]string)
 
-	var s *os.File
-	if update {
-		// Update existing file
-		s, err = os.OpenFile(m.ServicesPath, os.O_WRONLY|os.O_TRUNC, 0644)
+	for _, l := range m.layers() {
+		loaded, sources, err := l.load()
 		if err != nil {
-			return fmt.Errorf("failed to open services file: %w", err)
-		}
-		defer s.Close()
-	} else {
-		// Create new file
-		if m.Verbosity >= types.Normal {
-			fmt.Println("[+] Info: Creating templates directory...")
+			return nil, fmt.Errorf("failed loading templates from %s: %w", l, err)
 		}
 
-		// Create templates directory if it doesn't exist
-		if err := os.MkdirAll(m.TemplatesDir, 0755); err != nil {
-			return fmt.Errorf("failed to create templates directory: %w", err)
+		if l.dir == "" {
+			if err := duplicates(sources); err != nil {
+				return nil, err
+			}
+		} else {
+			for id, files := range sources {
+				userSources[id] = append(userSources[id], files...)
+			}
 		}
 
-		// Create services.json file
-		s, err = os.Create(m.ServicesPath)
-		if err != nil {
-			return fmt.Errorf("failed to create services file: %w", err)
+		for _, service := range loaded {
+			byID[service.ID] = service
+			m.Sources[service.ID] = sources[service.ID]
 		}
-		defer s.Close()
 	}
 
-	// Write the content to file
-	if _, err := s.Write(body); err != nil {
-		return fmt.Errorf("failed to write services file: %w", err)
+	if err := duplicates(userSources); err != nil {
+		return nil, err
 	}
 
-	if m.Verbosity >= types.Normal {
-		fmt.Println("[+] Info: Successfully pulled the latest templates!")
+	if len(byID) == 0 {
+		return nil, fmt.Errorf("%w in %s", ErrNoTemplates, strings.Join(m.TemplatesDirs, ", "))
 	}
 
-	return nil
+	services := slices.SortedFunc(maps.Values(byID), func(a, b types.Service) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	return services, nil
+}
+
+// SetEmbedded sets the templates embedded in the binary, which are used until templates are installed
+func (m *Manager) SetEmbedded(fsys fs.FS) {
+	m.Embedded = fsys
 }
 
-// GetService returns services by ID or name
+// GetService returns services by ID, name or name glob (such as "atlassian*")
 func (m *Manager) GetService(ids string, services []types.Service) []types.Service {
 	if ids == "*" {
 		return services
@@ -131,9 +113,7 @@ func (m *Manager) GetService(ids string, services []types.Service) []types.Servi
 
 	for _, service := range services {
 		for _, id := range parsed {
-			id = strings.TrimSpace(id)
-			if (fmt.Sprintf("%v", service.ID) == id) ||
-				(strings.EqualFold(fmt.Sprintf("%v", service.Metadata.Service), id)) {
+			if matchService(service, strings.TrimSpace(id)) {
 				result = append(result, service)
 				break // Found a match, move to the next service
 			}
@@ -149,29 +129,34 @@ func (m *Manager) PrintServices(services []types.Service, width int) {
 		fmt.Printf("[+] %v Service(s) loaded!\n", len(services))
 	}
 
-	// Print the table header
-	fmt.Println("| ID | Service")
-	fmt.Printf("|----|--%s\n", strings.Repeat("-", width-6))
+	// Size the columns to the longest value
+	nameWidth, categoryWidth, tagsWidth := len("Service"), len("Category"), len("Tags")
+	for _, service := range services {
+		nameWidth = max(nameWidth, len(service.Metadata.ServiceName))
+		categoryWidth = max(categoryWidth, len(service.Metadata.Category))
+		tagsWidth = max(tagsWidth, len(strings.Join(service.Metadata.Tags, ",")))
+	}
+
+	// Print the table header, the separator does not exceed the terminal width
+	fmt.Printf("| ID  | %-*s | Severity | %-*s | Tags\n", nameWidth, "Service", categoryWidth, "Category")
+	separator := fmt.Sprintf("|-----|-%s-|----------|-%s-|-%s",
+		strings.Repeat("-", nameWidth), strings.Repeat("-", categoryWidth), strings.Repeat("-", tagsWidth))
+	fmt.Println(separator[:min(len(separator), max(width, 6))])
 
 	// Print each row of the table
 	for _, service := range services {
-		fmt.Printf("| %-2d | %-7s\n", service.ID, service.Metadata.ServiceName)
+		fmt.Printf("| %-3d | %-*s | %-8s | %-*s | %s\n", service.ID, nameWidth, service.Metadata.ServiceName,
+			service.Metadata.Severity, categoryWidth, service.Metadata.Category, strings.Join(service.Metadata.Tags, ","))
 	}
 }
 
-// IsFile checks if a path is a file
+// IsFile checks if a path is an existing regular file
 func IsFile(path string) bool {
-	if filepath.Ext(path) == "" {
-		return false
-	}
-
-	_, err := os.Stat(path)
-	return !os.IsNotExist(err)
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular()
 }
 
 // ParseRegex transforms an array of patterns into a regex string
 func ParseRegex(v []string) string {
-	x := strings.Join(v, "|")             // Split array entries with regex alternation
-	x = strings.Replace(x, ".", `\.`, -1) // Escape dot characters
-	return x
+	return matchers.ParseRegex(v)
 }
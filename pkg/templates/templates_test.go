@@ -0,0 +1,248 @@
+package templates
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/intigriti/misconfig-mapper/internal/types"
+)
+
+// servicesJSON returns a list of valid templates with the given service IDs
+func servicesJSON(t *testing.T, ids ...int64) []byte {
+	t.Helper()
+
+	services := make([]types.Service, 0, len(ids))
+	for _, id := range ids {
+		service := validService()
+		service.ID = id
+		service.Metadata.ServiceName = fmt.Sprintf("Example %d", id)
+		services = append(services, service)
+	}
+
+	data, err := json.Marshal(services)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// writeServices writes a template file with the given service IDs
+func writeServices(t *testing.T, file string, ids ...int64) {
+	t.Helper()
+
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(file, servicesJSON(t, ids...), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// testManager returns a silent template manager with embedded templates for services 0 and 1
+func testManager(t *testing.T, dirs ...string) *Manager {
+	t.Helper()
+
+	m := NewManager(dirs, types.Silent)
+	m.SetEmbedded(fstest.MapFS{servicesFile: {Data: servicesJSON(t, 0, 1)}})
+	return m
+}
+
+// ids returns the service IDs of a list of templates
+func ids(services []types.Service) []int64 {
+	var ids []int64
+	for _, service := range services {
+		ids = append(ids, service.ID)
+	}
+	return ids
+}
+
+// changeIDs returns the service IDs of a list of template changes
+func changeIDs(changes []ServiceChange) []int64 {
+	var ids []int64
+	for _, change := range changes {
+		ids = append(ids, change.ID)
+	}
+	return ids
+}
+
+func TestLoadTemplatesInstalled(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string][]int64 // Files of the templates directory and the service IDs they define
+		want  []int64
+	}{
+		{
+			name: "nothing installed",
+			want: []int64{0, 1},
+		},
+		{
+			name:  "custom templates on top of the embedded templates",
+			files: map[string][]int64{"custom/example.json": {1, 5}},
+			want:  []int64{0, 1, 5},
+		},
+		{
+			name:  "installed templates replace the embedded templates",
+			files: map[string][]int64{servicesFile: {0, 2}},
+			want:  []int64{0, 2},
+		},
+		{
+			name:  "custom templates next to installed templates",
+			files: map[string][]int64{servicesFile: {0}, "custom/example.json": {5}},
+			want:  []int64{0, 5},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, fileIDs := range tt.files {
+				writeServices(t, filepath.Join(dir, filepath.FromSlash(name)), fileIDs...)
+			}
+
+			services, err := testManager(t, dir).LoadTemplates()
+			if err != nil {
+				t.Fatalf("LoadTemplates() error = %v", err)
+			}
+			if got := ids(services); !slices.Equal(got, tt.want) {
+				t.Errorf("LoadTemplates() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpdateTemplatesReplacesEmbedded(t *testing.T) {
+	source := t.TempDir()
+	writeServices(t, filepath.Join(source, servicesFile), 0, 2)
+
+	dir := t.TempDir()
+	m := testManager(t, dir)
+	m.SetSource(source)
+	m.SetSkipVerify(true)
+
+	diff, err := m.UpdateTemplates(context.Background(), false)
+	if err != nil {
+		t.Fatalf("UpdateTemplates() error = %v", err)
+	}
+	if got := changeIDs(diff.Added); !slices.Equal(got, []int64{2}) {
+		t.Errorf("added = %v, want [2]", got)
+	}
+	if got := changeIDs(diff.Removed); !slices.Equal(got, []int64{1}) {
+		t.Errorf("removed = %v, want [1]", got)
+	}
+
+	// The services reported as removed must no longer be loaded
+	services, err := m.LoadTemplates()
+	if err != nil {
+		t.Fatalf("LoadTemplates() error = %v", err)
+	}
+	if got := ids(services); !slices.Equal(got, []int64{0, 2}) {
+		t.Errorf("LoadTemplates() = %v, want [0 2]", got)
+	}
+}
+
+func TestLoadTemplatesDuplicates(t *testing.T) {
+	tests := []struct {
+		name    string
+		dirs    []map[string][]int64 // Files of each templates directory and the service IDs they define
+		want    []int64
+		wantErr string
+	}{
+		{
+			name: "folder overrides an embedded template",
+			dirs: []map[string][]int64{{"custom.json": {1, 2}}},
+			want: []int64{0, 1, 2},
+		},
+		{
+			name: "separate IDs across folders",
+			dirs: []map[string][]int64{{"custom.json": {2}}, {"private.json": {3}}},
+			want: []int64{0, 1, 2, 3},
+		},
+		{
+			name:    "duplicate ID across folders",
+			dirs:    []map[string][]int64{{"custom.json": {1, 2}}, {"private.json": {2}}},
+			wantErr: "duplicate service ID 2 defined in",
+		},
+		{
+			name:    "duplicate ID in a folder",
+			dirs:    []map[string][]int64{{"custom.json": {2}, "team/other.json": {2}}},
+			wantErr: "duplicate service ID 2 defined in",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var dirs, files []string
+			for _, dirFiles := range tt.dirs {
+				dir := t.TempDir()
+				for name, fileIDs := range dirFiles {
+					file := filepath.Join(dir, filepath.FromSlash(name))
+					writeServices(t, file, fileIDs...)
+					files = append(files, file)
+				}
+				dirs = append(dirs, dir)
+			}
+
+			m := testManager(t, dirs...)
+			services, err := m.LoadTemplates()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("LoadTemplates() error = %v, want %q", err, tt.wantErr)
+				}
+				// Both files are named
+				for _, file := range files {
+					if !strings.Contains(err.Error(), file) {
+						t.Errorf("LoadTemplates() error = %v, want it to name %s", err, file)
+					}
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadTemplates() error = %v", err)
+			}
+
+			if got := ids(services); !slices.Equal(got, tt.want) {
+				t.Errorf("LoadTemplates() = %v, want %v", got, tt.want)
+			}
+			// The embedded template is replaced, not reported as a duplicate
+			if got := m.Sources[1]; len(got) != 1 {
+				t.Errorf("Sources[1] = %v, want a single file", got)
+			}
+		})
+	}
+}
+
+func TestIsFile(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"targets", "targets.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("intigriti\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "folder.txt"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{path: filepath.Join(dir, "targets"), want: true},
+		{path: filepath.Join(dir, "targets.txt"), want: true},
+		{path: filepath.Join(dir, "folder.txt")},
+		{path: filepath.Join(dir, "missing.txt")},
+		{path: "intigriti.com"},
+	}
+
+	for _, tt := range tests {
+		if got := IsFile(tt.path); got != tt.want {
+			t.Errorf("IsFile(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,448 @@
+package templates
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/intigriti/misconfig-mapper/internal/types"
+)
+
+const (
+	// DefaultSource is the location of the official template releases. The templates-release tag points at the latest
+	// release with a signed manifest, the manifest of the main branch is outdated as soon as a template changes.
+	DefaultSource = "https://raw.githubusercontent.com/intigriti/misconfig-mapper/templates-release/templates"
+
+	servicesFile  = "services.json" // Only file of template sources without a manifest
+	previousDir   = ".previous"     // Holds the templates replaced by the last update, for rollbacks
+	updateTimeout = 30 * time.Second
+	maxUpdateSize = 32 << 20 // Maximum size of a downloaded template file
+)
+
+// SetSource sets the URL or local folder that templates are updated from
+func (m *Manager) SetSource(source string) {
+	if source == "" {
+		source = DefaultSource
+	}
+	m.Source = source
+}
+
+// SetPublicKey sets the key that template manifests must be signed with
+func (m *Manager) SetPublicKey(key ed25519.PublicKey) {
+	m.PublicKey = key
+}
+
+// SetSkipVerify accepts template updates that aren't signed or whose signature can't be verified
+func (m *Manager) SetSkipVerify(skip bool) {
+	m.SkipVerify = skip
+}
+
+// UpdateTemplates updates the templates of the primary templates directory from the update source and returns the changed services.
+// The manifest of the source must be signed with the public key, unless verification is skipped. Downloaded files are checked
+// against the manifest and validated before they replace the installed templates, which are kept for a rollback.
+// Sources without a manifest only provide a services.json file and can only be used if verification is skipped.
+// A dry run only reports the changes without writing anything to disk, it returns nil if the templates are up to date.
+func (m *Manager) UpdateTemplates(ctx context.Context, dryRun bool) (*Diff, error) {
+	if m.Verbosity >= types.Normal {
+		if dryRun {
+			fmt.Printf("[+] Info: Checking latest templates from %s against %v (dry run)\n", m.Source, m.TemplatesDir)
+		} else {
+			fmt.Printf("[+] Info: Pulling latest templates from %s and saving in %v\n", m.Source, m.TemplatesDir)
+		}
+	}
+
+	if !m.SkipVerify && m.PublicKey == nil {
+		return nil, fmt.Errorf("no public key configured to verify the templates with")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, updateTimeout)
+	defer cancel()
+
+	files := make(map[string][]byte)
+
+	manifestData, err := m.fetch(ctx, manifestFile)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	var manifest, installed *Manifest
+	if err != nil {
+		// Fall back to the single services.json file of sources without a manifest
+		if !m.SkipVerify {
+			return nil, fmt.Errorf("update source has no signed manifest")
+		}
+		if m.Verbosity >= types.Normal {
+			fmt.Fprintf(os.Stderr, "[-] Warning: Update source has no manifest, the templates can't be verified!\n")
+		}
+
+		data, err := m.fetch(ctx, servicesFile)
+		if err != nil {
+			return nil, err
+		}
+		files[servicesFile] = data
+	} else {
+		if !m.SkipVerify {
+			signature, err := m.fetch(ctx, signatureFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to fetch manifest signature: %w", err)
+			}
+			if err := VerifySignature(m.PublicKey, manifestData, signature); err != nil {
+				return nil, err
+			}
+			files[signatureFile] = signature
+		} else if m.Verbosity >= types.Normal {
+			fmt.Fprintf(os.Stderr, "[-] Warning: Signature verification is disabled, the manifest signature is not verified!\n")
+		}
+
+		manifest, err = ParseManifest(manifestData)
+		if err != nil {
+			return nil, err
+		}
+
+		// Refuse older releases, which may reintroduce broken or compromised templates
+		installed, err = m.InstalledManifest()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read installed manifest: %w", err)
+		}
+		if installed != nil && manifest.Version < installed.Version {
+			return nil, fmt.Errorf("refusing to downgrade templates from version %d to %d", installed.Version, manifest.Version)
+		}
+		if installed != nil && manifest.Version == installed.Version {
+			if m.Verbosity >= types.Normal {
+				fmt.Printf("[+] Info: Templates are up to date (version %d)\n", manifest.Version)
+			}
+			return nil, nil
+		}
+
+		for _, name := range manifest.Names() {
+			data, err := m.fetch(ctx, name)
+			if err != nil {
+				return nil, err
+			}
+			if err := manifest.Verify(name, data); err != nil {
+				return nil, err
+			}
+			files[name] = data
+		}
+		files[manifestFile] = manifestData
+	}
+
+	if err := validateUpdate(files); err != nil {
+		return nil, err
+	}
+
+	names := slices.Sorted(maps.Keys(files))
+
+	// Compare the templates of the update with the templates it replaces
+	old, err := m.replaced()
+	if err != nil && m.Verbosity >= types.Normal {
+		fmt.Fprintf(os.Stderr, "[-] Warning: Failed to load the installed templates to compare the update with: %v\n", err)
+	}
+	updated, err := parseFiles(files, names)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := DiffServices(old, updated)
+	if installed != nil {
+		diff.FromVersion = installed.Version
+	}
+	if manifest != nil {
+		diff.ToVersion = manifest.Version
+	}
+
+	if dryRun {
+		return diff, nil
+	}
+
+	// Stage the update next to the installed templates so it can be moved in place
+	if err := os.MkdirAll(m.TemplatesDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create templates directory: %w", err)
+	}
+
+	staging, err := os.MkdirTemp(m.TemplatesDir, ".update-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to stage templates: %w", err)
+	}
+	defer os.RemoveAll(staging)
+
+	for name, data := range files {
+		file := filepath.Join(staging, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			return nil, fmt.Errorf("failed to stage templates: %w", err)
+		}
+		if err := os.WriteFile(file, data, 0644); err != nil {
+			return nil, fmt.Errorf("failed to stage templates: %w", err)
+		}
+	}
+
+	if err := m.install(staging, names); err != nil {
+		return nil, err
+	}
+
+	if m.Verbosity >= types.Normal {
+		if manifest != nil {
+			fmt.Printf("[+] Info: Successfully updated the templates to version %d!\n", manifest.Version)
+		} else {
+			fmt.Println("[+] Info: Successfully pulled the latest templates!")
+		}
+	}
+
+	return diff, nil
+}
+
+// RollbackTemplates restores the templates that were replaced by the last update or rollback
+func (m *Manager) RollbackTemplates() error {
+	previous := filepath.Join(m.TemplatesDir, previousDir)
+
+	var names []string
+	err := filepath.WalkDir(previous, func(file string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		name, err := filepath.Rel(previous, file)
+		if err != nil {
+			return err
+		}
+		names = append(names, filepath.ToSlash(name))
+
+		return nil
+	})
+	if errors.Is(err, fs.ErrNotExist) || (err == nil && len(names) == 0) {
+		return fmt.Errorf("no previous templates found in %s", m.TemplatesDir)
+	} else if err != nil {
+		return err
+	}
+
+	// Move the previous templates out of the way, installing them replaces the previous templates with the current ones
+	staging, err := os.MkdirTemp(m.TemplatesDir, ".rollback-")
+	if err != nil {
+		return fmt.Errorf("failed to stage templates: %w", err)
+	}
+	defer os.RemoveAll(staging)
+
+	restore := filepath.Join(staging, previousDir)
+	if err := os.Rename(previous, restore); err != nil {
+		return fmt.Errorf("failed to stage templates: %w", err)
+	}
+
+	if err := m.install(restore, names); err != nil {
+		return err
+	}
+
+	if m.Verbosity >= types.Normal {
+		installed, _ := m.InstalledManifest()
+		if installed != nil {
+			fmt.Printf("[+] Info: Rolled back the templates to version %d!\n", installed.Version)
+		} else {
+			fmt.Println("[+] Info: Rolled back the templates!")
+		}
+	}
+
+	return nil
+}
+
+// install moves staged files into the primary templates directory.
+// The installed files are copied to the previous templates folder first and removed if the staged files don't replace them.
+// They are restored if a staged file can't be moved in place.
+func (m *Manager) install(staging string, names []string) error {
+	current, err := m.installedFiles()
+	if err != nil {
+		return err
+	}
+
+	previous := filepath.Join(m.TemplatesDir, previousDir)
+	if err := os.RemoveAll(previous); err != nil {
+		return fmt.Errorf("failed to back up templates: %w", err)
+	}
+
+	for _, name := range current {
+		data, err := os.ReadFile(filepath.Join(m.TemplatesDir, filepath.FromSlash(name)))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return fmt.Errorf("failed to back up templates: %w", err)
+		}
+
+		file := filepath.Join(previous, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			return fmt.Errorf("failed to back up templates: %w", err)
+		}
+		if err := os.WriteFile(file, data, 0644); err != nil {
+			return fmt.Errorf("failed to back up templates: %w", err)
+		}
+	}
+
+	// Renaming replaces each file at once, so the templates are never partially written
+	for i, name := range names {
+		file := filepath.Join(m.TemplatesDir, filepath.FromSlash(name))
+		err := os.MkdirAll(filepath.Dir(file), 0755)
+		if err == nil {
+			err = os.Rename(filepath.Join(staging, filepath.FromSlash(name)), file)
+		}
+		if err != nil {
+			if restoreErr := m.restore(previous, current, names[:i]); restoreErr != nil {
+				return fmt.Errorf("failed to install templates: %w (restoring the previous templates failed: %v)", err, restoreErr)
+			}
+			return fmt.Errorf("failed to install templates, the previous templates were restored: %w", err)
+		}
+	}
+
+	for _, name := range current {
+		if slices.Contains(names, name) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(m.TemplatesDir, filepath.FromSlash(name))); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("failed to remove outdated template: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// restore removes the files of a failed install and restores the backed up templates
+func (m *Manager) restore(previous string, current, installed []string) error {
+	for _, name := range installed {
+		if err := os.Remove(filepath.Join(m.TemplatesDir, filepath.FromSlash(name))); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+
+	for _, name := range current {
+		data, err := os.ReadFile(filepath.Join(previous, filepath.FromSlash(name)))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(m.TemplatesDir, filepath.FromSlash(name)), data, 0644); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// replaced returns the templates that an update replaces: the installed templates, or the embedded templates
+// if no templates are installed yet
+func (m *Manager) replaced() ([]types.Service, error) {
+	if !m.installed() {
+		if m.Embedded == nil {
+			return nil, nil
+		}
+		services, _, err := layer{fsys: m.Embedded}.load()
+		return services, err
+	}
+
+	current, err := m.installedFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	return loadFiles(m.TemplatesDir, current)
+}
+
+// installedFiles returns the files of the installed templates: the files of the installed manifest or the services.json file
+func (m *Manager) installedFiles() ([]string, error) {
+	installed, err := m.InstalledManifest()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read installed manifest: %w", err)
+	}
+
+	if installed == nil {
+		return []string{servicesFile}, nil
+	}
+
+	return append(installed.Names(), manifestFile, signatureFile), nil
+}
+
+// fetch reads a file of the update source, a missing file is reported as fs.ErrNotExist
+func (m *Manager) fetch(ctx context.Context, name string) ([]byte, error) {
+	if !strings.HasPrefix(m.Source, "http://") && !strings.HasPrefix(m.Source, "https://") {
+		data, err := os.ReadFile(filepath.Join(m.Source, filepath.FromSlash(name)))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		return data, nil
+	}
+
+	u := strings.TrimSuffix(m.Source, "/") + "/" + name
+
+	req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	client := http.Client{}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("failed to fetch %s: %w", u, fs.ErrNotExist)
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch %s: unexpected status code %d", u, res.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, maxUpdateSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", u, err)
+	}
+	if len(body) > maxUpdateSize {
+		return nil, fmt.Errorf("failed to fetch %s: file exceeds %d bytes", u, maxUpdateSize)
+	}
+
+	return body, nil
+}
+
+// validateUpdate validates the downloaded templates of an update, updates with any invalid template are rejected
+func validateUpdate(files map[string][]byte) error {
+	validator := NewValidator()
+	for _, name := range slices.Sorted(maps.Keys(files)) {
+		if isTemplateFile(name) {
+			validator.validateData(name, files[name])
+		}
+	}
+
+	if len(validator.Issues) > 0 {
+		issues := make([]string, 0, len(validator.Issues))
+		for _, issue := range validator.Issues {
+			issues = append(issues, issue.String())
+		}
+		return fmt.Errorf("update rejected, found %d problem(s) in the new templates:\n%s",
+			len(validator.Issues), strings.Join(issues, "\n"))
+	}
+
+	if validator.Services == 0 {
+		return fmt.Errorf("update rejected: %w", ErrNoTemplates)
+	}
+
+	return nil
+}
+
+// isTemplateFile reports whether a file of an update holds templates, the same files are validated as in a templates folder.
+// The manifest, fixtures and the files of hidden folders don't.
+func isTemplateFile(name string) bool {
+	for _, dir := range strings.Split(path.Dir(name), "/") {
+		if dir == fixturesDir || (dir != "." && strings.HasPrefix(dir, ".")) {
+			return false
+		}
+	}
+
+	return path.Base(name) != manifestFile && slices.Contains(templateExtensions, strings.ToLower(path.Ext(name)))
+}
@@ -0,0 +1,236 @@
+package templates
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/intigriti/misconfig-mapper/internal/types"
+)
+
+// writeRelease writes a template release with the given files and service IDs to a new folder and signs its manifest
+func writeRelease(t *testing.T, version int64, key ed25519.PrivateKey, files map[string][]int64) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, fileIDs := range files {
+		writeServices(t, filepath.Join(dir, filepath.FromSlash(name)), fileIDs...)
+	}
+	if _, err := GenerateManifest(dir, version, key); err != nil {
+		t.Fatal(err)
+	}
+
+	return dir
+}
+
+// updateManager returns a template manager that updates the templates of dir from a source signed with key
+func updateManager(t *testing.T, dir, source string, key ed25519.PublicKey) *Manager {
+	t.Helper()
+
+	m := NewManager([]string{dir}, types.Silent)
+	m.SetSource(source)
+	m.SetPublicKey(key)
+	return m
+}
+
+// loadedIDs returns the service IDs of the templates that are loaded from dir
+func loadedIDs(t *testing.T, dir string) []int64 {
+	t.Helper()
+
+	services, err := NewManager([]string{dir}, types.Silent).LoadTemplates()
+	if err != nil {
+		t.Fatalf("LoadTemplates() error = %v", err)
+	}
+	return ids(services)
+}
+
+// installedVersion returns the version of the templates installed in dir
+func installedVersion(t *testing.T, dir string) int64 {
+	t.Helper()
+
+	manifest, err := NewManager([]string{dir}, types.Silent).InstalledManifest()
+	if err != nil || manifest == nil {
+		t.Fatalf("InstalledManifest() = %v, %v", manifest, err)
+	}
+	return manifest.Version
+}
+
+func TestUpdateTemplatesRejected(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherPublic, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		key    ed25519.PublicKey
+		modify func(t *testing.T, source string)
+		want   string
+	}{
+		{
+			name: "checksum mismatch",
+			key:  public,
+			modify: func(t *testing.T, source string) {
+				writeServices(t, filepath.Join(source, servicesFile), 0, 2, 3)
+			},
+			want: "checksum mismatch for services.json",
+		},
+		{
+			name: "modified manifest",
+			key:  public,
+			modify: func(t *testing.T, source string) {
+				file := filepath.Join(source, manifestFile)
+				data, err := os.ReadFile(file)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(file, []byte(strings.Replace(string(data), `"version": 2`, `"version": 3`, 1)), 0644); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: "invalid manifest signature",
+		},
+		{
+			name: "missing signature",
+			key:  public,
+			modify: func(t *testing.T, source string) {
+				if err := os.Remove(filepath.Join(source, signatureFile)); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: "failed to fetch manifest signature",
+		},
+		{
+			name: "signed with another key",
+			key:  otherPublic,
+			want: "invalid manifest signature",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if _, err := updateManager(t, dir, writeRelease(t, 1, private, map[string][]int64{servicesFile: {0, 1}}), public).UpdateTemplates(context.Background(), false); err != nil {
+				t.Fatalf("UpdateTemplates() error = %v", err)
+			}
+
+			source := writeRelease(t, 2, private, map[string][]int64{servicesFile: {0, 2}})
+			if tt.modify != nil {
+				tt.modify(t, source)
+			}
+
+			_, err := updateManager(t, dir, source, tt.key).UpdateTemplates(context.Background(), false)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("UpdateTemplates() error = %v, want %q", err, tt.want)
+			}
+
+			// The installed templates are left untouched
+			if got := loadedIDs(t, dir); !slices.Equal(got, []int64{0, 1}) {
+				t.Errorf("loaded services = %v, want [0 1]", got)
+			}
+			if got := installedVersion(t, dir); got != 1 {
+				t.Errorf("installed version = %d, want 1", got)
+			}
+		})
+	}
+}
+
+func TestUpdateTemplatesDowngrade(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	if _, err := updateManager(t, dir, writeRelease(t, 2, private, map[string][]int64{servicesFile: {0, 1}}), public).UpdateTemplates(context.Background(), false); err != nil {
+		t.Fatalf("UpdateTemplates() error = %v", err)
+	}
+
+	_, err = updateManager(t, dir, writeRelease(t, 1, private, map[string][]int64{servicesFile: {0}}), public).UpdateTemplates(context.Background(), false)
+	if err == nil || !strings.Contains(err.Error(), "refusing to downgrade templates from version 2 to 1") {
+		t.Fatalf("UpdateTemplates() error = %v, want a downgrade error", err)
+	}
+}
+
+func TestUpdateTemplatesFailedInstall(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	if _, err := updateManager(t, dir, writeRelease(t, 1, private, map[string][]int64{servicesFile: {0, 1}}), public).UpdateTemplates(context.Background(), false); err != nil {
+		t.Fatalf("UpdateTemplates() error = %v", err)
+	}
+
+	// A folder in place of a file of the update fails the install after the manifest and services.json were replaced
+	if err := os.Mkdir(filepath.Join(dir, "team.json"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	source := writeRelease(t, 2, private, map[string][]int64{servicesFile: {0, 2}, "team.json": {3}})
+	_, err = updateManager(t, dir, source, public).UpdateTemplates(context.Background(), false)
+	if err == nil || !strings.Contains(err.Error(), "the previous templates were restored") {
+		t.Fatalf("UpdateTemplates() error = %v, want a restored install error", err)
+	}
+
+	// The previous templates are restored
+	if got := loadedIDs(t, dir); !slices.Equal(got, []int64{0, 1}) {
+		t.Errorf("loaded services = %v, want [0 1]", got)
+	}
+	if got := installedVersion(t, dir); got != 1 {
+		t.Errorf("installed version = %d, want 1", got)
+	}
+}
+
+func TestRollbackTemplates(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	m := updateManager(t, dir, writeRelease(t, 1, private, map[string][]int64{servicesFile: {0, 1}}), public)
+	if err := m.RollbackTemplates(); err == nil || !strings.Contains(err.Error(), "no previous templates found") {
+		t.Fatalf("RollbackTemplates() error = %v, want no previous templates", err)
+	}
+	if _, err := m.UpdateTemplates(context.Background(), false); err != nil {
+		t.Fatalf("UpdateTemplates() error = %v", err)
+	}
+
+	m = updateManager(t, dir, writeRelease(t, 2, private, map[string][]int64{servicesFile: {0}, "team.json": {3}}), public)
+	if _, err := m.UpdateTemplates(context.Background(), false); err != nil {
+		t.Fatalf("UpdateTemplates() error = %v", err)
+	}
+	if got := loadedIDs(t, dir); !slices.Equal(got, []int64{0, 3}) {
+		t.Errorf("loaded services after the update = %v, want [0 3]", got)
+	}
+
+	// Rolling back removes the files that the update added
+	if err := m.RollbackTemplates(); err != nil {
+		t.Fatalf("RollbackTemplates() error = %v", err)
+	}
+	if got := loadedIDs(t, dir); !slices.Equal(got, []int64{0, 1}) {
+		t.Errorf("loaded services after the rollback = %v, want [0 1]", got)
+	}
+	if got := installedVersion(t, dir); got != 1 {
+		t.Errorf("installed version after the rollback = %d, want 1", got)
+	}
+
+	// Rolling back again restores the update
+	if err := m.RollbackTemplates(); err != nil {
+		t.Fatalf("RollbackTemplates() error = %v", err)
+	}
+	if got := installedVersion(t, dir); got != 2 {
+		t.Errorf("installed version after the second rollback = %d, want 2", got)
+	}
+}
@@ -0,0 +1,579 @@
+package templates
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/intigriti/misconfig-mapper/internal/permutation"
+	"github.com/intigriti/misconfig-mapper/internal/types"
+	"github.com/intigriti/misconfig-mapper/pkg/matchers"
+	"gopkg.in/yaml.v3"
+)
+
+// methodPattern matches valid HTTP request methods
+var methodPattern = regexp.MustCompile(`^[A-Z]+$`)
+
+// BodyEncodings are the supported encodings of request bodies
+var BodyEncodings = []string{types.EncodingJSON, types.EncodingForm, types.EncodingRaw, types.EncodingBase64}
+
+// Problem is a schema violation of a single template
+type Problem struct {
+	Field   string // JSON path of the offending field, e.g. "request.path[1]"
+	Message string
+}
+
+// Error returns a readable representation of the problem
+func (p Problem) Error() string {
+	return fmt.Sprintf("%s: %s", p.Field, p.Message)
+}
+
+// Check checks a decoded template against the template schema
+func Check(service *types.Service) []Problem {
+	var problems []Problem
+	add := func(field, format string, a ...any) {
+		problems = append(problems, Problem{Field: field, Message: fmt.Sprintf(format, a...)})
+	}
+
+	if service.ID < 0 {
+		add("id", "must not be negative")
+	}
+
+	// Variables
+	defaults := make(map[string]string, len(service.Variables))
+	for _, name := range slices.Sorted(maps.Keys(service.Variables)) {
+		field := fmt.Sprintf("variables.%s", name)
+
+		if !variablePattern.MatchString(name) {
+			add(field, "invalid variable name %q", name)
+		} else if slices.Contains(reservedVariables, name) {
+			add(field, "%q is a reserved variable name", name)
+		}
+		if len(service.Variables[name]) == 0 {
+			add(field, "at least one value is required")
+		} else {
+			defaults[name] = service.Variables[name][0]
+		}
+	}
+
+	// Request
+	request := service.Request
+
+	if request.Method == "" {
+		add("request.method", "is required")
+	} else if !methodPattern.MatchString(request.Method) {
+		add("request.method", "invalid HTTP method %q", request.Method)
+	}
+
+	if request.BaseURL == "" {
+		add("request.baseURL", "is required")
+	} else if u, err := url.Parse(strings.ReplaceAll(Interpolate(request.BaseURL, defaults), "{TARGET}", "target")); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		add("request.baseURL", "must be an absolute http(s) URL, got %q", request.BaseURL)
+	}
+
+	if len(request.Path) == 0 {
+		add("request.path", "at least one path is required")
+	}
+	for i, path := range request.Path {
+		if !strings.HasPrefix(path, "/") {
+			add(fmt.Sprintf("request.path[%d]", i), "must start with \"/\", got %q", path)
+		}
+	}
+
+	if request.DetectionPath != "" && !strings.HasPrefix(request.DetectionPath, "/") {
+		add("request.detectionPath", "must start with \"/\", got %q", request.DetectionPath)
+	}
+
+	body, _ := json.Marshal(request.Body)
+	if !strings.Contains(request.BaseURL, "{TARGET}") && !strings.Contains(request.DetectionPath, "{TARGET}") &&
+		!slices.ContainsFunc(request.Path, func(path string) bool { return strings.Contains(path, "{TARGET}") }) &&
+		!strings.Contains(string(body), "{TARGET}") {
+		add("request.baseURL", "the {TARGET} placeholder is missing from the base URL, paths and body")
+	}
+
+	checkPlaceholders("request.baseURL", request.BaseURL, service.Variables, add)
+	checkPlaceholders("request.detectionPath", request.DetectionPath, service.Variables, add)
+	for i, path := range request.Path {
+		checkPlaceholders(fmt.Sprintf("request.path[%d]", i), path, service.Variables, add)
+	}
+
+	// The headers of the service are also sent with every step, so they can use the values captured by the steps
+	captured := maps.Clone(service.Variables)
+	if captured == nil {
+		captured = make(map[string][]string)
+	}
+	for _, step := range request.Steps {
+		for _, extractor := range step.Extractors {
+			captured[extractor.Name] = nil
+		}
+	}
+	checkHeaderPlaceholders("request.headers", request.Headers, captured, add)
+
+	checkBody("request", request.Body, request.BodyEncoding, add)
+
+	available := maps.Clone(service.Variables)
+	if available == nil {
+		available = make(map[string][]string)
+	}
+	for i, step := range request.Steps {
+		field := fmt.Sprintf("request.steps[%d]", i)
+
+		checkBody(field, step.Body, step.BodyEncoding, add)
+
+		// Steps can use the values captured by the previous steps
+		checkHeaderPlaceholders(field+".headers", step.Headers, available, add)
+		for _, extractor := range step.Extractors {
+			available[extractor.Name] = nil
+		}
+
+		if step.Method != "" && !methodPattern.MatchString(step.Method) {
+			add(field+".method", "invalid HTTP method %q", step.Method)
+		}
+		if step.Path != "" && !strings.HasPrefix(step.Path, "/") && !strings.HasPrefix(step.Path, "{") &&
+			!strings.HasPrefix(step.Path, "http://") && !strings.HasPrefix(step.Path, "https://") {
+			add(field+".path", "must start with \"/\", a variable or http(s)://, got %q", step.Path)
+		}
+		if step.Matchers != nil {
+			if _, err := matchers.Compile(*step.Matchers); err != nil {
+				add(field+".matchers", "%v", err)
+			}
+		}
+		if _, err := matchers.CompileExtractors(step.Extractors); err != nil {
+			add(field+".extractors", "%v", err)
+		}
+	}
+
+	// Response
+	response := service.Response
+
+	if response.Matchers == nil || response.StatusCode != nil {
+		if _, err := matchers.ParseStatusCodes(response.StatusCode); err != nil {
+			add("response.statusCode", "%v", err)
+		}
+	}
+
+	if response.Matchers == nil && len(response.Fingerprints) == 0 {
+		add("response.fingerprints", "at least one fingerprint is required (or define matchers)")
+	}
+
+	for field, patterns := range map[string][]string{
+		"response.detectionFingerprints": response.DetectionFingerprints,
+		"response.fingerprints":          response.Fingerprints,
+		"response.exclusionPatterns":     response.ExclusionPatterns,
+	} {
+		for i, pattern := range patterns {
+			if _, err := regexp.Compile(matchers.ParseRegex([]string{pattern})); err != nil {
+				add(fmt.Sprintf("%s[%d]", field, i), "invalid regex: %v", err)
+			}
+		}
+	}
+
+	if response.DetectionMatchers != nil {
+		if _, err := matchers.Compile(*response.DetectionMatchers); err != nil {
+			add("response.detectionMatchers", "%v", err)
+		}
+	}
+	if response.Matchers != nil {
+		if _, err := matchers.Compile(*response.Matchers); err != nil {
+			add("response.matchers", "%v", err)
+		}
+	}
+	if _, err := matchers.CompileExtractors(response.Extractors); err != nil {
+		add("response.extractors", "%v", err)
+	}
+
+	if service.PermutationRules != nil {
+		if _, err := permutation.NewFilter(*service.PermutationRules); err != nil {
+			add("permutationRules.allowedCharacters", "%v", err)
+		}
+	}
+
+	// Metadata
+	if service.Metadata.Service == "" {
+		add("metadata.service", "is required")
+	}
+	if service.Metadata.ServiceName == "" {
+		add("metadata.serviceName", "is required")
+	}
+	if service.Metadata.Severity != "" && !slices.Contains(Severities, service.Metadata.Severity) {
+		add("metadata.severity", "invalid severity %q (must be one of %s)", service.Metadata.Severity, strings.Join(Severities, ", "))
+	}
+	for i, tag := range service.Metadata.Tags {
+		if tag == "" || strings.ContainsAny(tag, ",+! ") {
+			add(fmt.Sprintf("metadata.tags[%d]", i), "invalid tag %q (must not be empty or contain spaces, \",\", \"+\" or \"!\")", tag)
+		}
+	}
+
+	// Sort the problems by field as the patterns are checked in map order
+	slices.SortStableFunc(problems, func(a, b Problem) int {
+		return strings.Compare(a.Field, b.Field)
+	})
+
+	return problems
+}
+
+// checkPlaceholders reports the placeholders in a base URL or path that are neither reserved nor declared as a variable
+func checkPlaceholders(field, value string, variables map[string][]string, add func(field, format string, a ...any)) {
+	for _, name := range placeholders(value) {
+		if _, ok := variables[name]; !ok && !slices.Contains(reservedVariables, name) {
+			add(field, "the {%s} placeholder is not declared in variables", name)
+		}
+	}
+}
+
+// checkHeaderPlaceholders reports the placeholders in header values that are neither reserved nor declared as a variable
+func checkHeaderPlaceholders(field string, headers []map[string]string, variables map[string][]string, add func(field, format string, a ...any)) {
+	for i, header := range headers {
+		for _, key := range slices.Sorted(maps.Keys(header)) {
+			checkPlaceholders(fmt.Sprintf("%s[%d].%s", field, i, key), header[key], variables, add)
+		}
+	}
+}
+
+// checkBody reports a body encoding that is not supported or doesn't fit the request body
+func checkBody(field string, body any, encoding string, add func(field, format string, a ...any)) {
+	if encoding != "" && !slices.Contains(BodyEncodings, encoding) {
+		add(field+".bodyEncoding", "invalid body encoding %q (must be one of %s)", encoding, strings.Join(BodyEncodings, ", "))
+		return
+	}
+
+	s, isString := body.(string)
+	_, isObject := body.(map[string]any)
+
+	switch {
+	case body == nil:
+	case encoding == types.EncodingForm && !isString && !isObject:
+		add(field+".body", "a form encoded body must be an object or a string")
+	case (encoding == types.EncodingRaw || encoding == types.EncodingBase64) && !isString:
+		add(field+".body", "a %s body must be a string", encoding)
+	case encoding == types.EncodingBase64:
+		if _, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s)); err != nil {
+			add(field+".body", "invalid base64 body: %v", err)
+		}
+	}
+}
+
+// Issue is a problem found in a template file
+type Issue struct {
+	File    string
+	Line    int
+	Column  int
+	Message string
+}
+
+// String returns the issue prefixed with its position
+func (i Issue) String() string {
+	if i.Line == 0 {
+		return fmt.Sprintf("%s: %s", i.File, i.Message)
+	}
+	return fmt.Sprintf("%s:%d:%d: %s", i.File, i.Line, i.Column, i.Message)
+}
+
+// Validator validates template files and checks service IDs are unique across all of them
+type Validator struct {
+	Files    int     // Number of template files validated
+	Services int     // Number of templates validated
+	Issues   []Issue // Issues found so far
+
+	ids map[int64]string // Position of the first definition of each service ID
+}
+
+// NewValidator creates a new template validator
+func NewValidator() *Validator {
+	return &Validator{ids: make(map[int64]string)}
+}
+
+// ValidatePath validates a template file, or all template files in a directory (recursively)
+func (v *Validator) ValidatePath(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	if !info.IsDir() {
+		return v.ValidateFile(path)
+	}
+
+	return filepath.WalkDir(path, func(file string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			// Skip fixtures and hidden directories (such as .git)
+			if file != path && (d.Name() == fixturesDir || strings.HasPrefix(d.Name(), ".")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if d.Name() == manifestFile || !slices.Contains(templateExtensions, strings.ToLower(filepath.Ext(file))) {
+			return nil
+		}
+
+		return v.ValidateFile(file)
+	})
+}
+
+// ValidateFile validates all templates in a JSON or YAML file
+func (v *Validator) ValidateFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed opening file '%s': %w", path, err)
+	}
+
+	v.validateData(path, data)
+	return nil
+}
+
+// validateData validates all templates in the content of a JSON or YAML file
+func (v *Validator) validateData(path string, data []byte) {
+	v.Files++
+
+	isYAML := strings.EqualFold(filepath.Ext(path), ".yaml") || strings.EqualFold(filepath.Ext(path), ".yml")
+
+	// Parse the file into a YAML node tree to find the position of each field (JSON is a subset of YAML)
+	var root yaml.Node
+	if err := yaml.Unmarshal(yamlCompatible(data, isYAML), &root); err != nil {
+		if isYAML {
+			v.Issues = append(v.Issues, Issue{File: path, Message: fmt.Sprintf("invalid YAML: %v", err)})
+			return
+		}
+		root = yaml.Node{} // Positions are unavailable, but the JSON can still be validated
+	}
+
+	if isYAML {
+		var document any
+		if err := root.Decode(&document); err != nil {
+			v.Issues = append(v.Issues, Issue{File: path, Message: fmt.Sprintf("invalid YAML: %v", err)})
+			return
+		}
+		converted, err := json.Marshal(document)
+		if err != nil {
+			v.Issues = append(v.Issues, Issue{File: path, Message: fmt.Sprintf("invalid YAML: %v", err)})
+			return
+		}
+		data = converted
+	}
+
+	// Split the file into its templates
+	var elements []json.RawMessage
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] == '{' {
+		elements = []json.RawMessage{trimmed}
+	} else if err := json.Unmarshal(data, &elements); err != nil {
+		issue := Issue{File: path, Message: fmt.Sprintf("invalid JSON: %v", err)}
+
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) && !isYAML {
+			issue.Line, issue.Column = offsetPosition(data, syntaxErr.Offset)
+		}
+		v.Issues = append(v.Issues, issue)
+		return
+	}
+
+	nodes := templateNodes(&root)
+
+	for i, element := range elements {
+		var node *yaml.Node
+		if i < len(nodes) {
+			node = nodes[i]
+		}
+		v.validateTemplate(path, element, node, i)
+	}
+}
+
+// validateTemplate validates a single template of a file
+func (v *Validator) validateTemplate(path string, element json.RawMessage, node *yaml.Node, index int) {
+	v.Services++
+
+	report := func(field, message string) {
+		issue := Issue{File: path, Message: message}
+		issue.Line, issue.Column = locate(node, field)
+		v.Issues = append(v.Issues, issue)
+	}
+
+	// Every template needs a unique ID, the zero value can't be told apart from a missing one
+	var fields struct {
+		ID *int64 `json:"id"`
+	}
+	if err := json.Unmarshal(element, &fields); err == nil {
+		if fields.ID == nil {
+			report("", fmt.Sprintf("template %d: id: is required", index))
+		} else {
+			position := path
+			if line, column := locate(node, "id"); line > 0 {
+				position = fmt.Sprintf("%s:%d:%d", path, line, column)
+			}
+
+			if first, ok := v.ids[*fields.ID]; ok {
+				report("id", fmt.Sprintf("service %d: duplicate service ID (first defined at %s)", *fields.ID, first))
+			} else {
+				v.ids[*fields.ID] = position
+			}
+		}
+	}
+
+	// Reject unknown fields (typos) and fields of the wrong type
+	var service types.Service
+	decoder := json.NewDecoder(bytes.NewReader(element))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&service); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		switch {
+		case errors.As(err, &typeErr):
+			report(typeErr.Field, fmt.Sprintf("template %d: %s: must be of type %s, got %s", index, typeErr.Field, typeErr.Type, typeErr.Value))
+			return
+		case strings.HasPrefix(err.Error(), "json: unknown field "):
+			name, _ := strconv.Unquote(strings.TrimPrefix(err.Error(), "json: unknown field "))
+			issue := Issue{File: path, Message: fmt.Sprintf("template %d: unknown field %q", index, name)}
+			if key := findKey(node, name); key != nil {
+				issue.Line, issue.Column = key.Line, key.Column
+			}
+			v.Issues = append(v.Issues, issue)
+
+			// Keep checking the known fields
+			if err := json.Unmarshal(element, &service); err != nil {
+				return
+			}
+		default:
+			report("", fmt.Sprintf("template %d: %v", index, err))
+			return
+		}
+	}
+
+	for _, problem := range Check(&service) {
+		report(problem.Field, fmt.Sprintf("service %d: %v", service.ID, problem))
+	}
+}
+
+// yamlCompatible rewrites the escaped forward slashes of a JSON document, which YAML doesn't support
+func yamlCompatible(data []byte, isYAML bool) []byte {
+	if isYAML || !bytes.Contains(data, []byte(`\/`)) {
+		return data
+	}
+
+	result := make([]byte, 0, len(data))
+	for i := 0; i < len(data); i++ {
+		if data[i] == '\\' && i+1 < len(data) {
+			if data[i+1] == '/' {
+				result = append(result, '/')
+			} else {
+				result = append(result, data[i], data[i+1])
+			}
+			i++
+			continue
+		}
+		result = append(result, data[i])
+	}
+
+	return result
+}
+
+// templateNodes returns the nodes of the templates in a file, which holds a single template or a list of templates
+func templateNodes(root *yaml.Node) []*yaml.Node {
+	if root.Kind != yaml.DocumentNode || len(root.Content) == 0 {
+		return nil
+	}
+
+	switch node := root.Content[0]; node.Kind {
+	case yaml.MappingNode:
+		return []*yaml.Node{node}
+	case yaml.SequenceNode:
+		return node.Content
+	default:
+		return nil
+	}
+}
+
+// locate returns the position of a field such as "request.path[1]" in a template node.
+// It falls back to the position of the closest parent if the field itself is missing.
+func locate(node *yaml.Node, field string) (int, int) {
+	if node == nil {
+		return 0, 0
+	}
+	line, column := node.Line, node.Column
+
+	for _, segment := range strings.Split(field, ".") {
+		if segment == "" {
+			continue
+		}
+
+		key, indexes, _ := strings.Cut(segment, "[")
+
+		// Find the key in the mapping
+		found := false
+		if node.Kind == yaml.MappingNode {
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				if node.Content[i].Value == key {
+					line, column = node.Content[i].Line, node.Content[i].Column
+					node = node.Content[i+1]
+					found = true
+					break
+				}
+			}
+		}
+		if !found {
+			return line, column
+		}
+
+		// Follow the array indexes
+		for _, index := range strings.Split(indexes, "[") {
+			i, err := strconv.Atoi(strings.TrimSuffix(index, "]"))
+			if err != nil || node.Kind != yaml.SequenceNode || i < 0 || i >= len(node.Content) {
+				break
+			}
+			node = node.Content[i]
+			line, column = node.Line, node.Column
+		}
+	}
+
+	return line, column
+}
+
+// findKey returns the first mapping key with the given name in a node tree
+func findKey(node *yaml.Node, name string) *yaml.Node {
+	if node == nil {
+		return nil
+	}
+
+	if node.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == name {
+				return node.Content[i]
+			}
+		}
+	}
+
+	for _, child := range node.Content {
+		if key := findKey(child, name); key != nil {
+			return key
+		}
+	}
+
+	return nil
+}
+
+// offsetPosition converts a byte offset into a line and column
+func offsetPosition(data []byte, offset int64) (int, int) {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+
+	before := data[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	column := int(offset) - bytes.LastIndexByte(before, '\n')
+
+	return line, column
+}
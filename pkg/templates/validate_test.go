@@ -0,0 +1,243 @@
+package templates
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/intigriti/misconfig-mapper/internal/types"
+)
+
+// validService returns a template without any problems
+func validService() types.Service {
+	var service types.Service
+	service.ID = 100
+	service.Request.Method = "GET"
+	service.Request.BaseURL = "https://{TARGET}.example.com"
+	service.Request.Path = []string{"/"}
+	service.Response.StatusCode = float64(200)
+	service.Response.Fingerprints = []string{"Welcome"}
+	service.Metadata.Service = "example"
+	service.Metadata.ServiceName = "Example"
+	return service
+}
+
+// fields returns the fields of the problems found in a template
+func fields(problems []Problem) []string {
+	var fields []string
+	for _, problem := range problems {
+		fields = append(fields, problem.Field)
+	}
+	return fields
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*types.Service)
+		want   []string
+	}{
+		{
+			name:   "valid",
+			modify: func(s *types.Service) {},
+		},
+		{
+			name: "missing required fields",
+			modify: func(s *types.Service) {
+				s.Request.Method = ""
+				s.Request.Path = nil
+				s.Response.Fingerprints = nil
+				s.Metadata.Service = ""
+				s.Metadata.ServiceName = ""
+			},
+			want: []string{"metadata.service", "metadata.serviceName", "request.method", "request.path", "response.fingerprints"},
+		},
+		{
+			name: "invalid request",
+			modify: func(s *types.Service) {
+				s.ID = -1
+				s.Request.Method = "get"
+				s.Request.BaseURL = "ftp://{TARGET}.example.com"
+				s.Request.Path = []string{"/", "admin"}
+				s.Request.DetectionPath = "login"
+			},
+			want: []string{"id", "request.baseURL", "request.detectionPath", "request.method", "request.path[1]"},
+		},
+		{
+			name: "missing target placeholder",
+			modify: func(s *types.Service) {
+				s.Request.BaseURL = "https://example.com"
+			},
+			want: []string{"request.baseURL"},
+		},
+		{
+			name: "target placeholder in a path",
+			modify: func(s *types.Service) {
+				s.Request.BaseURL = "https://example.com"
+				s.Request.Path = []string{"/{TARGET}/login"}
+			},
+		},
+		{
+			name: "undeclared variable",
+			modify: func(s *types.Service) {
+				s.Request.Path = []string{"/{region}/login"}
+			},
+			want: []string{"request.path[0]"},
+		},
+		{
+			name: "declared variable",
+			modify: func(s *types.Service) {
+				s.Variables = map[string][]string{"region": {"", "eu."}}
+				s.Request.BaseURL = "https://{TARGET}.{region}example.com"
+			},
+		},
+		{
+			name: "invalid variables",
+			modify: func(s *types.Service) {
+				s.Variables = map[string][]string{"TARGET": {"example"}, "region": {}}
+			},
+			want: []string{"variables.TARGET", "variables.region"},
+		},
+		{
+			name: "invalid response",
+			modify: func(s *types.Service) {
+				s.Response.StatusCode = "ok"
+				s.Response.Fingerprints = []string{"Welcome", "[unclosed"}
+			},
+			want: []string{"response.fingerprints[1]", "response.statusCode"},
+		},
+		{
+			name: "invalid metadata",
+			modify: func(s *types.Service) {
+				s.Metadata.Severity = "urgent"
+				s.Metadata.Tags = []string{"ok", "two words", ""}
+			},
+			want: []string{"metadata.severity", "metadata.tags[1]", "metadata.tags[2]"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := validService()
+			tt.modify(&service)
+
+			if got := fields(Check(&service)); !slices.Equal(got, tt.want) {
+				t.Errorf("Check() problems = %v, want %v", Check(&service), tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckHeaderPlaceholders(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*types.Service)
+		want   []string
+	}{
+		{
+			name: "reserved placeholders",
+			modify: func(s *types.Service) {
+				s.Request.Headers = []map[string]string{{"Host": "{TARGET}.example.com", "Referer": "{BASE_URL}{PATH}"}}
+			},
+		},
+		{
+			name: "declared variable",
+			modify: func(s *types.Service) {
+				s.Variables = map[string][]string{"region": {"eu"}}
+				s.Request.Headers = []map[string]string{{"X-Region": "{region}"}}
+			},
+		},
+		{
+			name: "unknown placeholder",
+			modify: func(s *types.Service) {
+				s.Request.Headers = []map[string]string{{"Accept": "*/*"}, {"X-Region": "{region}", "X-Tenant": "{tenant}"}}
+			},
+			want: []string{"request.headers[1].X-Region", "request.headers[1].X-Tenant"},
+		},
+		{
+			name: "captured by a step",
+			modify: func(s *types.Service) {
+				s.Request.Headers = []map[string]string{{"X-CSRF-Token": "{csrf}"}}
+				s.Request.Steps = []types.Step{
+					{Extractors: []types.Extractor{{Name: "csrf", Type: "header", Header: "X-CSRF-Token"}}},
+					{Headers: []map[string]string{{"Cookie": "csrf={csrf}"}}},
+				}
+			},
+		},
+		{
+			name: "captured by a later step",
+			modify: func(s *types.Service) {
+				s.Request.Steps = []types.Step{
+					{Headers: []map[string]string{{"Cookie": "csrf={csrf}"}}},
+					{Extractors: []types.Extractor{{Name: "csrf", Type: "header", Header: "X-CSRF-Token"}}},
+				}
+			},
+			want: []string{"request.steps[0].headers[0].Cookie"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := validService()
+			tt.modify(&service)
+
+			if got := fields(Check(&service)); !slices.Equal(got, tt.want) {
+				t.Errorf("Check() problems = %v, want %v", Check(&service), tt.want)
+			}
+		})
+	}
+}
+
+func TestValidatePath(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"services.json": string(servicesJSON(t, 0, 1)),
+		// Service 1 is already defined in services.json
+		"team/duplicate.yaml": "id: 1\nrequest:\n  method: GET\n  baseURL: https://{TARGET}.example.com\n  path: [/]\n" +
+			"response:\n  statusCode: 200\n  fingerprints: [Welcome]\nmetadata:\n  service: example\n  serviceName: Example\n",
+		"team/typo.json":   `{"id": 2, "request": {"method": "GET", "baseURL": "https://{TARGET}.example.com", "path": ["/"]}, "respnse": {}}`,
+		"team/type.json":   `[{"id": "3"}]`,
+		"team/broken.json": "[\n  {\"id\": 4,\n",
+		// Fixtures aren't templates
+		"fixtures/0/fixture.json": `{"expect": "positive"}`,
+	}
+	for name, content := range files {
+		file := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	v := NewValidator()
+	if err := v.ValidatePath(dir); err != nil {
+		t.Fatalf("ValidatePath() error = %v", err)
+	}
+
+	if v.Files != 5 {
+		t.Errorf("Files = %d, want 5", v.Files)
+	}
+
+	want := map[string]string{
+		filepath.Join(dir, "team", "broken.json") + ":3:":    "invalid JSON",
+		filepath.Join(dir, "team", "duplicate.yaml") + ":1:": "service 1: duplicate service ID",
+		filepath.Join(dir, "team", "type.json") + ":":        "template 0: id: must be of type int64",
+		filepath.Join(dir, "team", "typo.json") + ":":        `template 0: unknown field "respnse"`,
+	}
+	for prefix, message := range want {
+		if !slices.ContainsFunc(v.Issues, func(issue Issue) bool {
+			return strings.HasPrefix(issue.String(), prefix) && strings.Contains(issue.Message, message)
+		}) {
+			t.Errorf("missing issue %s %q in %v", prefix, message, v.Issues)
+		}
+	}
+	for _, issue := range v.Issues {
+		if issue.File == filepath.Join(dir, "services.json") {
+			t.Errorf("unexpected issue %v", issue)
+		}
+	}
+}
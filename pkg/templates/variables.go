@@ -0,0 +1,199 @@
+package templates
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/intigriti/misconfig-mapper/internal/types"
+)
+
+// variablePattern matches valid template variable names
+var variablePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// placeholderPattern matches {NAME} placeholders
+var placeholderPattern = regexp.MustCompile(`\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// reservedVariables are filled in by the scanner and HTTP client and can't be declared by templates
+var reservedVariables = []string{"TARGET", "BASE_URL", "HOST", "PATH"}
+
+// Interpolate replaces the {NAME} placeholders in s with the values of the variables
+func Interpolate(s string, variables map[string]string) string {
+	if !strings.Contains(s, "{") {
+		return s
+	}
+
+	for name, value := range variables {
+		s = strings.ReplaceAll(s, fmt.Sprintf("{%s}", name), value)
+	}
+
+	return s
+}
+
+// InterpolateBody returns a copy of a request body with the {NAME} placeholders replaced in all of its strings,
+// including the keys of objects
+func InterpolateBody(body any, variables map[string]string) any {
+	switch b := body.(type) {
+	case string:
+		return Interpolate(b, variables)
+	case map[string]any:
+		result := make(map[string]any, len(b))
+		for key, value := range b {
+			result[Interpolate(key, variables)] = InterpolateBody(value, variables)
+		}
+		return result
+	case []any:
+		result := make([]any, len(b))
+		for i, value := range b {
+			result[i] = InterpolateBody(value, variables)
+		}
+		return result
+	default:
+		return body
+	}
+}
+
+// ExpandVariables substitutes the variables of each service into its base URL, paths, headers, bodies and fingerprints.
+// A service is expanded into one copy for each combination of variable values, overrides replace the values
+// declared by a template. The names of overrides that no service declares are returned as well.
+func ExpandVariables(services []types.Service, overrides map[string][]string) ([]types.Service, []string) {
+	used := make(map[string]bool)
+
+	var expanded []types.Service
+	for _, service := range services {
+		if len(service.Variables) == 0 {
+			expanded = append(expanded, service)
+			continue
+		}
+
+		variables := maps.Clone(service.Variables)
+		for name, values := range overrides {
+			if _, ok := variables[name]; ok {
+				variables[name] = values
+				used[name] = true
+			}
+		}
+
+		for _, combination := range combinations(variables) {
+			expanded = append(expanded, substitute(service, combination))
+		}
+	}
+
+	var unused []string
+	for name := range overrides {
+		if !used[name] {
+			unused = append(unused, name)
+		}
+	}
+	slices.Sort(unused)
+
+	return expanded, unused
+}
+
+// Variant returns the variable values of an expanded service, e.g. "instance=wd3", or an empty string
+// for a service without variables
+func Variant(service types.Service) string {
+	var values []string
+	for _, name := range slices.Sorted(maps.Keys(service.Variables)) {
+		values = append(values, fmt.Sprintf("%s=%s", name, strings.Join(service.Variables[name], ",")))
+	}
+
+	return strings.Join(values, " ")
+}
+
+// combinations returns every combination of variable values, ordered by variable name and value
+func combinations(variables map[string][]string) []map[string]string {
+	result := []map[string]string{{}}
+
+	for _, name := range slices.Sorted(maps.Keys(variables)) {
+		var next []map[string]string
+		for _, combination := range result {
+			for _, value := range variables[name] {
+				c := maps.Clone(combination)
+				c[name] = value
+				next = append(next, c)
+			}
+		}
+		result = next
+	}
+
+	return result
+}
+
+// substitute returns a copy of a service with the variables substituted in its requests
+func substitute(service types.Service, variables map[string]string) types.Service {
+	service.Variables = make(map[string][]string, len(variables))
+	for name, value := range variables {
+		service.Variables[name] = []string{value}
+	}
+
+	request := &service.Request
+	request.BaseURL = Interpolate(request.BaseURL, variables)
+	request.DetectionPath = Interpolate(request.DetectionPath, variables)
+	request.Path = slices.Clone(request.Path)
+	for i, path := range request.Path {
+		request.Path[i] = Interpolate(path, variables)
+	}
+	request.Headers = substituteHeaders(request.Headers, variables)
+	request.Body = InterpolateBody(request.Body, variables)
+
+	request.Steps = slices.Clone(request.Steps)
+	for i := range request.Steps {
+		step := &request.Steps[i]
+		step.Path = Interpolate(step.Path, variables)
+		step.Headers = substituteHeaders(step.Headers, variables)
+		step.Body = InterpolateBody(step.Body, variables)
+	}
+
+	// Fingerprints can match the hosts that depend on a variable
+	response := &service.Response
+	response.DetectionFingerprints = substituteAll(response.DetectionFingerprints, variables)
+	response.Fingerprints = substituteAll(response.Fingerprints, variables)
+	response.ExclusionPatterns = substituteAll(response.ExclusionPatterns, variables)
+
+	return service
+}
+
+// substituteAll returns a copy of a list of strings with the variables substituted in each of them
+func substituteAll(values []string, variables map[string]string) []string {
+	if values == nil {
+		return nil
+	}
+
+	result := make([]string, len(values))
+	for i, value := range values {
+		result[i] = Interpolate(value, variables)
+	}
+
+	return result
+}
+
+// substituteHeaders returns a copy of request headers with the variables substituted in their values
+func substituteHeaders(headers []map[string]string, variables map[string]string) []map[string]string {
+	if headers == nil {
+		return nil
+	}
+
+	result := make([]map[string]string, 0, len(headers))
+	for _, header := range headers {
+		h := make(map[string]string, len(header))
+		for key, value := range header {
+			h[key] = Interpolate(value, variables)
+		}
+		result = append(result, h)
+	}
+
+	return result
+}
+
+// placeholders returns the names of the {NAME} placeholders in s
+func placeholders(s string) []string {
+	var names []string
+	for _, match := range placeholderPattern.FindAllStringSubmatch(s, -1) {
+		names = append(names, match[1])
+	}
+
+	return names
+}
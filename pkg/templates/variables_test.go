@@ -0,0 +1,156 @@
+package templates
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/intigriti/misconfig-mapper/internal/types"
+)
+
+func TestInterpolate(t *testing.T) {
+	variables := map[string]string{"TARGET": "acme", "instance": "wd3"}
+
+	tests := []struct {
+		name string
+		s    string
+		want string
+	}{
+		{"no placeholders", "https://acme.example.com", "https://acme.example.com"},
+		{"placeholders", "https://{TARGET}.{instance}.example.com", "https://acme.wd3.example.com"},
+		{"repeated placeholder", "{TARGET}/{TARGET}", "acme/acme"},
+		{"unknown placeholder", "{TARGET}-{HOST}", "acme-{HOST}"},
+		{"case-sensitive", "{target}", "{target}"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Interpolate(tt.s, variables); got != tt.want {
+				t.Errorf("Interpolate(%q) = %q, want %q", tt.s, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInterpolateBody(t *testing.T) {
+	variables := map[string]string{"TARGET": "acme"}
+
+	body := map[string]any{
+		"query":      "{TARGET}",
+		"{TARGET}_a": []any{"{TARGET}", 1.0, true, nil, map[string]any{"name": "{TARGET}"}},
+	}
+	want := map[string]any{
+		"query":  "acme",
+		"acme_a": []any{"acme", 1.0, true, nil, map[string]any{"name": "acme"}},
+	}
+
+	if got := InterpolateBody(body, variables); !reflect.DeepEqual(got, want) {
+		t.Errorf("InterpolateBody() = %v, want %v", got, want)
+	}
+
+	// The template body is not modified
+	if body["query"] != "{TARGET}" {
+		t.Errorf("InterpolateBody() modified the original body: %v", body)
+	}
+}
+
+// workday returns a service with an instance and a region variable
+func workday() types.Service {
+	var service types.Service
+	service.ID = 21
+	service.Variables = map[string][]string{"instance": {"wd3"}, "region": {"eu", "us"}}
+	service.Request.BaseURL = "https://{instance}.{region}.example.com"
+	service.Request.Path = []string{"/{TARGET}/{instance}"}
+	service.Request.Headers = []map[string]string{{"X-Instance": "{instance}"}}
+	service.Request.Body = map[string]any{"region": "{region}"}
+	service.Request.Steps = []types.Step{{Path: "{PATH}?region={region}"}}
+	service.Response.Fingerprints = []string{"{instance}.example.com"}
+	return service
+}
+
+func TestExpandVariables(t *testing.T) {
+	var plain types.Service
+	plain.ID = 1
+	plain.Request.BaseURL = "https://{TARGET}.example.com"
+
+	tests := []struct {
+		name       string
+		overrides  map[string][]string
+		wantURLs   []string
+		wantUnused []string
+	}{
+		{
+			name:     "declared values",
+			wantURLs: []string{"https://{TARGET}.example.com", "https://wd3.eu.example.com", "https://wd3.us.example.com"},
+		},
+		{
+			name:      "overrides",
+			overrides: map[string][]string{"instance": {"wd1", "wd5"}, "region": {"eu"}},
+			wantURLs:  []string{"https://{TARGET}.example.com", "https://wd1.eu.example.com", "https://wd5.eu.example.com"},
+		},
+		{
+			name:       "unused overrides",
+			overrides:  map[string][]string{"tenant": {"acme"}, "instance": {"wd1"}, "env": {"dev"}},
+			wantURLs:   []string{"https://{TARGET}.example.com", "https://wd1.eu.example.com", "https://wd1.us.example.com"},
+			wantUnused: []string{"env", "tenant"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expanded, unused := ExpandVariables([]types.Service{plain, workday()}, tt.overrides)
+
+			var urls []string
+			for _, service := range expanded {
+				urls = append(urls, service.Request.BaseURL)
+			}
+			if !slices.Equal(urls, tt.wantURLs) {
+				t.Errorf("ExpandVariables() base URLs = %v, want %v", urls, tt.wantURLs)
+			}
+			if !slices.Equal(unused, tt.wantUnused) {
+				t.Errorf("ExpandVariables() unused = %v, want %v", unused, tt.wantUnused)
+			}
+		})
+	}
+}
+
+func TestExpandVariablesSubstitutes(t *testing.T) {
+	template := workday()
+	expanded, _ := ExpandVariables([]types.Service{template}, map[string][]string{"region": {"us"}})
+	if len(expanded) != 1 {
+		t.Fatalf("ExpandVariables() returned %d services, want 1", len(expanded))
+	}
+	service := expanded[0]
+
+	if got := Variant(service); got != "instance=wd3 region=us" {
+		t.Errorf("Variant() = %q, want %q", got, "instance=wd3 region=us")
+	}
+
+	// {TARGET} and {PATH} are filled in by the scanner
+	if got := service.Request.Path[0]; got != "/{TARGET}/wd3" {
+		t.Errorf("path = %q, want %q", got, "/{TARGET}/wd3")
+	}
+	if got := service.Request.Headers[0]["X-Instance"]; got != "wd3" {
+		t.Errorf("header = %q, want %q", got, "wd3")
+	}
+	if got := service.Request.Body; !reflect.DeepEqual(got, map[string]any{"region": "us"}) {
+		t.Errorf("body = %v, want map[region:us]", got)
+	}
+	if got := service.Request.Steps[0].Path; got != "{PATH}?region=us" {
+		t.Errorf("step path = %q, want %q", got, "{PATH}?region=us")
+	}
+	if got := service.Response.Fingerprints[0]; got != "wd3.example.com" {
+		t.Errorf("fingerprint = %q, want %q", got, "wd3.example.com")
+	}
+
+	// The template is not modified
+	if !reflect.DeepEqual(template, workday()) {
+		t.Errorf("ExpandVariables() modified the template: %+v", template)
+	}
+}
+
+func TestVariant(t *testing.T) {
+	if got := Variant(types.Service{}); got != "" {
+		t.Errorf("Variant() of a service without variables = %q, want an empty string", got)
+	}
+}
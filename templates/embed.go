@@ -0,0 +1,13 @@
+// Package templates embeds the default templates in the binary, so misconfig-mapper works without a templates folder
+package templates
+
+import "embed"
+
+// Bundle holds the default templates and their fixtures
+//
+//go:embed *.json fixtures
+var Bundle embed.FS
+
+// PublicKey is the PEM encoded Ed25519 key that the manifests of the official template releases are signed with.
+// The maintainers generate and hold the key pair, the public key is empty until they publish it.
+var PublicKey []byte